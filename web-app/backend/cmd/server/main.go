@@ -2,81 +2,129 @@
  * main.go - Discord Clone Backend Server
  * 
  * This is the main entry point for the Discord clone backend server.
- * It sets up the HTTP server with Google OAuth authentication, JWT middleware,
+ * It sets up the HTTP server with OAuth/OIDC authentication, JWT middleware,
  * and database connectivity.
  * 
  * Server Architecture:
  * - HTTP server using Go's standard net/http package
  * - PostgreSQL database with connection pooling
- * - JWT-based authentication with Google OAuth
+ * - JWT-based authentication with Google, GitHub, GitLab or any OIDC provider
  * - CORS middleware for frontend communication
  * - Environment variable configuration
  * 
  * Endpoints:
- * - /api/health: Health check endpoint
+ * - /livez, /readyz: Liveness and readiness probes (/api/health is /readyz)
  * - /api/hello: Test endpoint with optional authentication
- * - /auth/google/login: Initiates Google OAuth flow
- * - /auth/google/callback: Handles OAuth callback
+ * - /auth/providers: Lists configured login providers
+ * - /auth/{provider}/login: Initiates OAuth flow (e.g. /auth/google/login)
+ * - /auth/{provider}/callback: Handles OAuth callback
+ * - /auth/exchange: Exchanges the callback's sign-in code for tokens
+ * - /auth/register, /auth/login: Email/password accounts
+ * - /auth/mfa: Second step of sign-in for users with 2FA
+ * - /auth/refresh, /auth/logout: Refresh token rotation and revocation
+ * - /.well-known/jwks.json: Public keys for verifying access tokens
+ * - /api/servers: Server (guild) management (authenticated)
+ * - /api/servers/{id}/channels, /api/channels/{id}: Channel management (authenticated)
+ * - /api/channels/{id}/permissions: Channel permission overwrites (authenticated)
+ * - /api/channels/{id}/messages: Message send/list/edit/delete (authenticated)
+ * - /api/users/@me/channels, /api/channels/{id}/recipients: DMs and group DMs (authenticated)
+ * - /api/users/@me, /api/users/@me/username: Profile, display name and handle (authenticated)
+ * - /api/users/@me/mfa: TOTP enrollment and recovery codes (authenticated)
+ * - /api/users/@me/sessions: Signed-in devices and revocation (authenticated)
+ * - /api/users/@me/identities: Linked login provider accounts (authenticated)
+ * - /api/servers/{id}/members: Member list, leave and kick (authenticated)
+ * - /api/servers/{id}/roles: Role management and assignment (authenticated)
+ * - /api/servers/{id}/invites, /api/invites/{code}: Invite links
+ * - /gateway: WebSocket gateway for real-time events (JWT via IDENTIFY or auth cookie)
  * 
  * Key Components:
- * - AuthHandler: Manages Google OAuth and JWT generation
- * - JWTMiddleware: Validates tokens and injects user context
+ * - AuthHandler: Manages OAuth/OIDC login and JWT generation
+ * - ServerHandler: Server creation, updates and ownership checks
+ * - ChannelHandler: Channel CRUD and ordering within a server
+ * - MessageHandler: Channel messages with keyset pagination
+ * - MemberHandler: Server membership (list, leave, kick)
+ * - InviteHandler: Invite creation, preview, acceptance and revocation
+ * - RoleHandler: Server roles, permission bitfields and role assignment
+ * - OverwriteHandler: Per-channel role and member permission overwrites
+ * - DMHandler: Direct message and group DM conversations
+ * - UserHandler: Current user profile, display name and username changes
+ * - MFAHandler: TOTP two-factor enrollment and recovery codes
+ * - IdentityHandler: Linking and unlinking login provider accounts
+ * - gateway.Hub: Fans out server, channel, message and presence events
+ * - JWTMiddleware: Validates bearer or cookie tokens (CSRF-checked) and injects user context
  * - UserService: Database operations for user management
  * - CORS: Enables frontend-backend communication
  * 
- * Environment Setup:
- * Requires .env file with database and OAuth configuration
+ * Configuration:
+ * Settings come from an optional YAML file (-config or CONFIG_FILE), the
+ * environment (a .env file is loaded if present) and flags; see the config
+ * package. The server refuses to start if required values are missing.
+ * 
+ * Schema migrations are embedded and applied at startup; run
+ * "server migrate up|down [n]|status" to manage them by hand (migrate.go).
+ * 
+ * Shutdown:
+ * On SIGINT or SIGTERM the server stops accepting connections, lets
+ * in-flight requests finish, closes gateway connections with a Going Away
+ * frame and then closes the database pool, all within SHUTDOWN_TIMEOUT.
+ * A second signal exits immediately.
  */
 
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/user/web-app/internal/config"
+	"github.com/user/web-app/internal/gateway"
 	"github.com/user/web-app/internal/handlers"
 	"github.com/user/web-app/internal/middleware"
+	"github.com/user/web-app/internal/migrate"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/oauth"
+	"github.com/user/web-app/internal/tokens"
+	"github.com/user/web-app/migrations"
 	"github.com/user/web-app/pkg"
 )
 
-/**
- * Response - Standard API response structure
- * 
- * Used for basic API endpoints that return simple status information.
- */
-type Response struct {
-	Message string `json:"message"` // Human-readable response message
-	Status  string `json:"status"`  // Status indicator (success, error, etc.)
-}
-
 /**
  * enableCORS - CORS middleware for frontend communication
  * 
  * Enables Cross-Origin Resource Sharing to allow the React frontend
- * (localhost:5173) to communicate with the Go backend (localhost:8080).
+ * (FRONTEND_URL) to communicate with the Go backend (SERVER_ADDR).
  * 
  * CORS Headers:
  * - Access-Control-Allow-Origin: Specifies allowed origin (frontend URL)
  * - Access-Control-Allow-Methods: Allowed HTTP methods
  * - Access-Control-Allow-Headers: Headers that can be sent (includes Authorization for JWT)
+ * - Access-Control-Allow-Credentials: Lets the frontend send auth cookies
  * 
  * Handles preflight OPTIONS requests that browsers send for complex requests.
  * 
  * @param next The next HTTP handler in the middleware chain
+ * @param origin Frontend origin allowed to call the API
  * @return HTTP handler with CORS headers
  */
-func enableCORS(next http.Handler) http.Handler {
+func enableCORS(next http.Handler, origin string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow requests from React frontend
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
 		
 		// Allow standard HTTP methods
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		
 		// Allow Content-Type and Authorization headers (Authorization needed for JWT)
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		// plus the cookie authentication headers (see middleware/cookies.go)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Auth-Mode")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		
 		// Handle preflight OPTIONS requests
 		// Browsers send these before actual requests with custom headers
@@ -90,29 +138,10 @@ func enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-/**
- * healthHandler - Health check endpoint
- * 
- * Simple endpoint to verify the server is running and responsive.
- * Returns a basic JSON response with server status.
- * 
- * @param w HTTP response writer
- * @param r HTTP request
- */
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	response := Response{
-		Message: "Server is healthy",
-		Status:  "ok",
-	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
 /**
  * HelloResponse - Response structure for hello endpoint
  * 
- * Extends the basic Response with optional user information.
+ * A message and status with optional user information.
  * The User field is only populated if the request is authenticated.
  */
 type HelloResponse struct {
@@ -167,18 +196,18 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
  * 
  * Sets up and starts the HTTP server with all required components:
  * 1. Environment configuration
- * 2. Database connectivity
+ * 2. Database connectivity and schema migrations
  * 3. Authentication handlers
  * 4. Middleware chain
  * 5. Route configuration
  * 6. Server startup
  * 
  * Server Configuration:
- * - Port: 8080
+ * - Listen address: SERVER_ADDR or -addr (default :8080)
  * - CORS enabled for frontend communication
  * - JWT middleware for authentication
  * - PostgreSQL database connection
- * - Google OAuth integration
+ * - OAuth/OIDC provider integration
  */
 func main() {
 	log.Println("Starting Discord Clone Backend Server...")
@@ -190,50 +219,281 @@ func main() {
 		log.Printf("Make sure environment variables are set another way")
 	}
 
+	// Load and validate every setting before touching anything else
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	if len(args) > 0 && args[0] != "migrate" {
+		log.Fatalf("Unknown command %q (the only command is migrate)", args[0])
+	}
+
 	// Step 2: Connect to PostgreSQL database
-	// Uses connection details from environment variables
+	// Uses connection details from the configuration
 	log.Println("Connecting to database...")
-	db, err := pkg.ConnectDatabase()
+	db, err := pkg.ConnectDatabase(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close() // Ensure connection closes when main() exits
+	defer db.Close() // Closed when main() returns, after the server has drained
 	log.Println("Database connection established")
 
+	// "server migrate ..." manages the schema and exits (see migrate.go)
+	if len(args) > 0 {
+		if err := migrateCommand(db, args[1:]); err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before anything queries it
+	if !cfg.MigrateOnStart {
+		log.Println("Skipping migrations (MIGRATE_ON_START=false)")
+	} else if err := migrateOnStartup(db); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
 	// Step 3: Initialize authentication handler
-	// Sets up Google OAuth configuration and JWT signing
+	// Sets up login providers and JWT signing
 	log.Println("Initializing authentication handlers...")
-	authHandler := handlers.NewAuthHandler(db)
+	tokenConfig, err := cfg.JWT.TokenConfig()
+	if err != nil {
+		log.Fatal("Invalid token configuration:", err)
+	}
+	tokenService, err := tokens.NewService(tokenConfig)
+	if err != nil {
+		log.Fatal("Invalid token configuration:", err)
+	}
+
+	// Reject tokens whose session was revoked; active sessions are cached briefly
+	auth := middleware.NewAuthenticator(middleware.AuthConfig{
+		Tokens:        tokenService,
+		Sessions:      models.NewSessionService(db),
+		SessionTTL:    30 * time.Second,
+		SecureCookies: cfg.CookieSecure,
+	})
+	// withAuth wraps routes whose handlers require an authenticated user
+	withAuth := func(h http.HandlerFunc) http.Handler {
+		return auth.JWTMiddleware(h)
+	}
+
+	providers, err := oauth.NewRegistry(cfg.OAuth.ProviderConfigs())
+	if err != nil {
+		log.Fatal("Invalid login provider configuration:", err)
+	}
+	log.Printf("Login providers: %v", providers.Names())
+
+	// Gateway hub delivers real-time events published by the REST handlers
+	// and disconnects sessions revoked by the auth handlers
+	hub := gateway.NewHub(models.NewServerService(db), models.NewOverwriteService(db), auth, cfg.FrontendOrigin())
+
+	authHandler := handlers.NewAuthHandler(db, tokenService, auth, providers, cfg.FrontendURL, hub)
+	sessionHandler := handlers.NewSessionHandler(db, auth, hub)
+	identityHandler := handlers.NewIdentityHandler(db, auth, providers)
+	mfaHandler := handlers.NewMFAHandler(db)
+
+	serverHandler := handlers.NewServerHandler(db, hub)
+	channelHandler := handlers.NewChannelHandler(db, hub)
+	messageHandler := handlers.NewMessageHandler(db, hub)
+	memberHandler := handlers.NewMemberHandler(db, hub)
+	inviteHandler := handlers.NewInviteHandler(db, hub)
+	roleHandler := handlers.NewRoleHandler(db, hub)
+	overwriteHandler := handlers.NewOverwriteHandler(db, hub)
+	dmHandler := handlers.NewDMHandler(db, hub)
+	userHandler := handlers.NewUserHandler(db, hub)
+
+	// Readiness checks the database, the schema version and the gateway hub
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Invalid migrations:", err)
+	}
+	healthHandler := handlers.NewHealthHandler(db, migrator)
+	healthHandler.AddCheck("gateway", hub.Check)
 
 	// Step 4: Set up HTTP router with endpoints
 	mux := http.NewServeMux()
 	
-	// Health check endpoint (no authentication required)
-	mux.HandleFunc("/api/health", healthHandler)
+	// Health endpoints (no authentication required) - liveness never checks
+	// dependencies; readiness reports database, migrations and gateway
+	mux.HandleFunc("GET /livez", healthHandler.Live)
+	mux.HandleFunc("GET /readyz", healthHandler.Ready)
+	mux.HandleFunc("GET /api/health", healthHandler.Ready)
 	
 	// Test endpoint with optional authentication
 	// JWT middleware will add user context if token is provided
-	mux.Handle("/api/hello", middleware.JWTMiddleware(http.HandlerFunc(helloHandler)))
+	mux.Handle("/api/hello", auth.JWTMiddleware(http.HandlerFunc(helloHandler)))
+	
+	// OAuth / OIDC endpoints
+	mux.HandleFunc("GET /auth/providers", authHandler.ListProviders)           // Enabled providers
+	mux.HandleFunc("GET /auth/{provider}/login", authHandler.OAuthLogin)       // Start OAuth flow
+	mux.HandleFunc("GET /auth/{provider}/callback", authHandler.OAuthCallback) // Handle OAuth callback
+	mux.HandleFunc("POST /auth/exchange", authHandler.ExchangeCode)            // Redeem the callback's sign-in code
+
+	// Email/password endpoints
+	mux.HandleFunc("POST /auth/register", authHandler.Register)
+	mux.HandleFunc("POST /auth/login", authHandler.Login)
+	mux.HandleFunc("POST /auth/mfa", authHandler.VerifyMFA) // Complete a 2FA challenge
+
+	// Token endpoints
+	mux.HandleFunc("POST /auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("POST /auth/logout", authHandler.Logout)
+	mux.HandleFunc("GET /.well-known/jwks.json", authHandler.JWKS)
+	
+	// Server (guild) endpoints - handlers reject requests without a valid token
+	mux.Handle("POST /api/servers", withAuth(serverHandler.CreateServer))
+	mux.Handle("GET /api/servers", withAuth(serverHandler.ListMyServers))
+	mux.Handle("GET /api/servers/{id}", withAuth(serverHandler.GetServer))
+	mux.Handle("PATCH /api/servers/{id}", withAuth(serverHandler.UpdateServer))
+	mux.Handle("DELETE /api/servers/{id}", withAuth(serverHandler.DeleteServer))
+	
+	// Membership endpoints
+	mux.Handle("GET /api/servers/{id}/members", withAuth(memberHandler.ListMembers))
+	mux.Handle("DELETE /api/servers/{id}/members/@me", withAuth(memberHandler.LeaveServer))
+	mux.Handle("DELETE /api/servers/{id}/members/{userID}", withAuth(memberHandler.KickMember))
 	
-	// Google OAuth endpoints
-	mux.HandleFunc("/auth/google/login", authHandler.GoogleLogin)      // Start OAuth flow
-	mux.HandleFunc("/auth/google/callback", authHandler.GoogleCallback) // Handle OAuth callback
+	// Role endpoints - permission bitfields and member role assignment
+	mux.Handle("GET /api/servers/{id}/roles", withAuth(roleHandler.ListRoles))
+	mux.Handle("POST /api/servers/{id}/roles", withAuth(roleHandler.CreateRole))
+	mux.Handle("PUT /api/servers/{id}/roles/positions", withAuth(roleHandler.ReorderRoles))
+	mux.Handle("PATCH /api/servers/{id}/roles/{roleID}", withAuth(roleHandler.UpdateRole))
+	mux.Handle("DELETE /api/servers/{id}/roles/{roleID}", withAuth(roleHandler.DeleteRole))
+	mux.Handle("PUT /api/servers/{id}/members/{userID}/roles/{roleID}", withAuth(roleHandler.AddMemberRole))
+	mux.Handle("DELETE /api/servers/{id}/members/{userID}/roles/{roleID}", withAuth(roleHandler.RemoveMemberRole))
+	
+	// Invite endpoints - previews are public so invite links can be shown before login
+	mux.Handle("POST /api/servers/{id}/invites", withAuth(inviteHandler.CreateInvite))
+	mux.Handle("GET /api/servers/{id}/invites", withAuth(inviteHandler.ListInvites))
+	mux.Handle("DELETE /api/servers/{id}/invites/{code}", withAuth(inviteHandler.RevokeInvite))
+	mux.HandleFunc("GET /api/invites/{code}", inviteHandler.PreviewInvite)
+	mux.Handle("POST /api/invites/{code}/accept", withAuth(inviteHandler.AcceptInvite))
+	
+	// Channel endpoints
+	mux.Handle("GET /api/servers/{id}/channels", withAuth(channelHandler.ListChannels))
+	mux.Handle("POST /api/servers/{id}/channels", withAuth(channelHandler.CreateChannel))
+	mux.Handle("PUT /api/servers/{id}/channels/positions", withAuth(channelHandler.ReorderChannels))
+	mux.Handle("PATCH /api/channels/{id}", withAuth(channelHandler.RenameChannel))
+	mux.Handle("DELETE /api/channels/{id}", withAuth(channelHandler.DeleteChannel))
+	
+	// Channel permission overwrite endpoints - {type} is "role" or "member"
+	mux.Handle("GET /api/channels/{id}/permissions", withAuth(overwriteHandler.ListOverwrites))
+	mux.Handle("PUT /api/channels/{id}/permissions/{type}/{targetID}", withAuth(overwriteHandler.SetOverwrite))
+	mux.Handle("DELETE /api/channels/{id}/permissions/{type}/{targetID}", withAuth(overwriteHandler.DeleteOverwrite))
+	
+	// Message endpoints
+	mux.Handle("GET /api/channels/{id}/messages", withAuth(messageHandler.ListMessages))
+	mux.Handle("POST /api/channels/{id}/messages", withAuth(messageHandler.SendMessage))
+	mux.Handle("PATCH /api/channels/{id}/messages/{messageID}", withAuth(messageHandler.EditMessage))
+	mux.Handle("DELETE /api/channels/{id}/messages/{messageID}", withAuth(messageHandler.DeleteMessage))
+	
+	// Direct message endpoints - DM messages use the message endpoints above
+	mux.Handle("GET /api/users/@me/channels", withAuth(dmHandler.ListDMs))
+	mux.Handle("POST /api/users/@me/channels", withAuth(dmHandler.CreateDM))
+	mux.Handle("PUT /api/channels/{id}/recipients/{userID}", withAuth(dmHandler.AddRecipient))
+	mux.Handle("DELETE /api/channels/{id}/recipients/{userID}", withAuth(dmHandler.RemoveRecipient))
+
+	// Current user endpoints - username changes are rate limited
+	mux.Handle("GET /api/users/@me", withAuth(userHandler.GetCurrentUser))
+	mux.Handle("PATCH /api/users/@me", withAuth(userHandler.UpdateCurrentUser))
+	mux.Handle("PUT /api/users/@me/username", withAuth(userHandler.ChangeUsername))
+
+	// Two-factor authentication endpoints
+	mux.Handle("GET /api/users/@me/mfa", withAuth(mfaHandler.GetStatus))
+	mux.Handle("POST /api/users/@me/mfa/totp", withAuth(mfaHandler.StartTOTP))
+	mux.Handle("POST /api/users/@me/mfa/totp/confirm", withAuth(mfaHandler.ConfirmTOTP))
+	mux.Handle("DELETE /api/users/@me/mfa/totp", withAuth(mfaHandler.DisableTOTP))
+	mux.Handle("POST /api/users/@me/mfa/recovery-codes", withAuth(mfaHandler.RegenerateRecoveryCodes))
+
+	// Session endpoints
+	mux.Handle("GET /api/users/@me/sessions", withAuth(sessionHandler.ListSessions))
+	mux.Handle("DELETE /api/users/@me/sessions/{sessionID}", withAuth(sessionHandler.RevokeSession))
+
+	// Linked identity endpoints - a password or at least one identity is always kept
+	mux.Handle("GET /api/users/@me/identities", withAuth(identityHandler.ListIdentities))
+	mux.Handle("POST /api/users/@me/identities/{provider}/link", withAuth(identityHandler.StartLink))
+	mux.Handle("POST /api/users/@me/identities/confirm", withAuth(identityHandler.ConfirmLink))
+	mux.Handle("DELETE /api/users/@me/identities/{identityID}", withAuth(identityHandler.UnlinkIdentity))
+	
+	// WebSocket gateway - authenticates via the IDENTIFY opcode, not the middleware
+	mux.HandleFunc("GET /gateway", hub.ServeWS)
 	
 	// Step 5: Apply CORS middleware to entire router
 	// Enables frontend (React) to communicate with backend
-	handler := enableCORS(mux)
+	handler := enableCORS(mux, cfg.FrontendOrigin())
 	
 	// Step 6: Start HTTP server
-	log.Println("Server starting on :8080...")
+	log.Printf("Server starting on %s...", cfg.Addr)
 	log.Println("Available endpoints:")
-	log.Println("  GET  /api/health - Health check")
+	log.Println("  GET  /livez - Liveness probe")
+	log.Println("  GET  /readyz, /api/health - Readiness probe (database, migrations, gateway)")
 	log.Println("  GET  /api/hello - Test endpoint (optional auth)")
-	log.Println("  GET  /auth/google/login - Start Google OAuth")
-	log.Println("  GET  /auth/google/callback - OAuth callback")
-	log.Println("Frontend should be running on http://localhost:5173")
+	log.Println("  GET  /auth/providers - Enabled login providers")
+	log.Println("  GET  /auth/{provider}/login - Start OAuth login")
+	log.Println("  GET  /auth/{provider}/callback - OAuth callback")
+	log.Println("  POST /auth/exchange - Exchange a sign-in code for tokens")
+	log.Println("  POST /auth/register - Create an email/password account")
+	log.Println("  POST /auth/login - Log in with email and password")
+	log.Println("  POST /auth/mfa - Complete a two-factor sign-in")
+	log.Println("  POST /auth/refresh - Rotate a refresh token")
+	log.Println("  POST /auth/logout - Revoke a refresh token")
+	log.Println("  GET  /.well-known/jwks.json - Token verification keys")
+	log.Println("  *    /api/servers[/{id}] - Server management (auth required)")
+	log.Println("  *    /api/servers/{id}/members[/{userID}] - Membership (auth required)")
+	log.Println("  *    /api/servers/{id}/roles[/{roleID}] - Role management (auth required)")
+	log.Println("  *    /api/servers/{id}/invites[/{code}] - Invite management (auth required)")
+	log.Println("  GET  /api/invites/{code} - Invite preview")
+	log.Println("  POST /api/invites/{code}/accept - Join via invite (auth required)")
+	log.Println("  *    /api/servers/{id}/channels, /api/channels/{id} - Channel management (auth required)")
+	log.Println("  *    /api/channels/{id}/permissions[/{type}/{targetID}] - Channel overwrites (auth required)")
+	log.Println("  *    /api/channels/{id}/messages[/{messageID}] - Messages (auth required)")
+	log.Println("  *    /api/users/@me/channels, /api/channels/{id}/recipients - DMs (auth required)")
+	log.Println("  *    /api/users/@me[/username] - Profile and handle (auth required)")
+	log.Println("  *    /api/users/@me/mfa[/...] - Two-factor authentication (auth required)")
+	log.Println("  *    /api/users/@me/sessions[/{sessionID}] - Sessions (auth required)")
+	log.Println("  *    /api/users/@me/identities[/...] - Linked login providers (auth required)")
+	log.Println("  WS   /gateway - Real-time events (IDENTIFY with JWT)")
+	log.Printf("Frontend should be running on %s", cfg.FrontendURL)
 	
-	// Start server - this blocks until server shuts down
-	if err := http.ListenAndServe(":8080", handler); err != nil {
+	// Timeouts keep slow or idle clients from holding connections forever
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+	}
+
+	// Serve until SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
 		log.Fatal("Server failed to start:", err)
+	case <-ctx.Done():
+	}
+	stop() // Restore default handling so a second signal kills the process
+
+	// Step 7: Shut down gracefully
+	// http.Server.Shutdown waits for in-flight requests but ignores
+	// upgraded WebSockets, so the gateway closes those itself
+	log.Printf("Shutting down (waiting up to %s)...", cfg.HTTP.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	gatewayDone := make(chan error, 1)
+	go func() {
+		gatewayDone <- hub.Shutdown(shutdownCtx)
+	}()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not drain: %v", err)
+	}
+	if err := <-gatewayDone; err != nil {
+		log.Printf("Gateway connections did not close: %v", err)
 	}
+	log.Println("Server stopped")
 }
\ No newline at end of file
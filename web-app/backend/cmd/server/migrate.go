@@ -0,0 +1,117 @@
+/**
+ * migrate.go - Schema Migration Commands
+ *
+ * The server applies pending migrations on startup unless
+ * MIGRATE_ON_START is false. They can also be managed by hand:
+ *
+ *   server migrate up         Apply all pending migrations
+ *   server migrate down [n]   Revert the last n migrations (default 1)
+ *   server migrate status     List migrations and when they were applied
+ *
+ * See the migrations and migrate packages for how migrations are written
+ * and applied.
+ */
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/user/web-app/internal/migrate"
+	"github.com/user/web-app/migrations"
+)
+
+/**
+ * migrateOnStartup - Applies pending migrations before serving requests
+ *
+ * @param db Database connection
+ * @return error if loading or applying a migration fails
+ */
+func migrateOnStartup(db *sql.DB) error {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Database schema is at version %d", migrator.Latest())
+	return nil
+}
+
+/**
+ * migrateCommand - Runs "server migrate <up|down [n]|status>"
+ *
+ * @param db Database connection
+ * @param args Arguments after "migrate"
+ * @return error for unknown commands or failed migrations
+ */
+func migrateCommand(db *sql.DB, args []string) error {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				applied += " (not in this binary)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down [n] or status)", command)
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate blanks every setting the environment may already provide, so
+// only the variables a test sets are seen (loadEnv ignores empty values).
+func isolate(t *testing.T) {
+	t.Helper()
+	prefixes := []string{"SERVER_", "FRONTEND_", "COOKIE_", "MIGRATE_", "HTTP_", "SHUTDOWN_",
+		"DATABASE_", "POSTGRES_", "JWT_", "OAUTH_", "GOOGLE_", "GITHUB_", "GITLAB_", "OIDC_", "CONFIG_FILE"}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				t.Setenv(name, "")
+				break
+			}
+		}
+	}
+}
+
+// writeFile writes a temporary YAML config file and returns its path.
+func writeFile(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// required is the minimum YAML for a config that passes Validate. It
+// ends inside the database section so tests can add database keys.
+const required = `
+jwt:
+  secret: file-secret
+database:
+  user: file-user
+  name: file-db
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		env      map[string]string
+		args     []string
+		wantAddr string
+		wantHost string
+		wantUser string
+	}{
+		{
+			name:     "defaults",
+			wantAddr: ":8080",
+			wantHost: "localhost",
+			wantUser: "file-user",
+		},
+		{
+			name:     "file overrides defaults",
+			yaml:     "  host: file-host\naddr: :7000\n",
+			wantAddr: ":7000",
+			wantHost: "file-host",
+			wantUser: "file-user",
+		},
+		{
+			name:     "environment overrides file",
+			yaml:     "  host: file-host\naddr: :7000\n",
+			env:      map[string]string{"SERVER_ADDR": ":7001", "POSTGRES_HOST": "env-host", "POSTGRES_USER": "env-user"},
+			wantAddr: ":7001",
+			wantHost: "env-host",
+			wantUser: "env-user",
+		},
+		{
+			name:     "DATABASE_URL overrides individual settings",
+			yaml:     "  host: file-host\n",
+			env:      map[string]string{"POSTGRES_HOST": "env-host", "DATABASE_URL": "postgres://url-user@url-host/url-db"},
+			wantAddr: ":8080",
+			wantHost: "url-host",
+			wantUser: "url-user",
+		},
+		{
+			name:     "DATABASE_URL from the file still beats POSTGRES_HOST",
+			yaml:     "  url: postgres://url-user@url-host/url-db\n",
+			env:      map[string]string{"POSTGRES_HOST": "env-host"},
+			wantAddr: ":8080",
+			wantHost: "url-host",
+			wantUser: "url-user",
+		},
+		{
+			name:     "flags override everything",
+			yaml:     "addr: :7000\n",
+			env:      map[string]string{"SERVER_ADDR": ":7001"},
+			args:     []string{"-addr", ":7002"},
+			wantAddr: ":7002",
+			wantHost: "localhost",
+			wantUser: "file-user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := append([]string{"-config", writeFile(t, required+tt.yaml)}, tt.args...)
+
+			cfg, _, err := Load(args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Addr != tt.wantAddr {
+				t.Errorf("Addr = %q, want %q", cfg.Addr, tt.wantAddr)
+			}
+			if cfg.Database.Host != tt.wantHost {
+				t.Errorf("Database.Host = %q, want %q", cfg.Database.Host, tt.wantHost)
+			}
+			if cfg.Database.User != tt.wantUser {
+				t.Errorf("Database.User = %q, want %q", cfg.Database.User, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestLoadFileKeepsUnsetValues(t *testing.T) {
+	isolate(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, required+"http:\n  read_timeout: 1m\n"))
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HTTP.ReadTimeout != time.Minute {
+		t.Errorf("HTTP.ReadTimeout = %v, want 1m", cfg.HTTP.ReadTimeout)
+	}
+	if want := Default().HTTP.WriteTimeout; cfg.HTTP.WriteTimeout != want {
+		t.Errorf("HTTP.WriteTimeout = %v, want the default %v", cfg.HTTP.WriteTimeout, want)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		key  string
+	}{
+		{"misspelled top-level key", "adress: :9000\n", "adress"},
+		{"misspelled nested key", "  hostname: db\n", "hostname"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			_, _, err := Load([]string{"-config", writeFile(t, required+tt.yaml)})
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Fatalf("Load error = %v, want one naming %q", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoadReturnsRemainingArgs(t *testing.T) {
+	isolate(t)
+	_, rest, err := Load([]string{"-config", writeFile(t, required), "migrate", "down"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := []string{"migrate", "down"}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("remaining args = %q, want %q", rest, want)
+	}
+}
+
+func TestLoadReportsEveryEnvError(t *testing.T) {
+	isolate(t)
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	t.Setenv("POSTGRES_PORT", "five")
+	t.Setenv("COOKIE_SECURE", "maybe")
+
+	_, _, err := Load([]string{"-config", writeFile(t, required)})
+	if err == nil {
+		t.Fatal("Load accepted malformed environment variables")
+	}
+	for _, name := range []string{"HTTP_READ_TIMEOUT", "POSTGRES_PORT", "COOKIE_SECURE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Port = 0
+	cfg.Database.SSLMode = "sometimes"
+	cfg.HTTP.ShutdownTimeout = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an incomplete config")
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Validate returned %T, want errors joined with errors.Join", err)
+	}
+
+	want := []string{"SHUTDOWN_TIMEOUT", "POSTGRES_PORT", "POSTGRES_USER", "POSTGRES_DB", "POSTGRES_SSLMODE", "JWT_SECRET"}
+	if got := len(joined.Unwrap()); got != len(want) {
+		t.Errorf("Validate returned %d errors, want %d: %v", got, len(want), err)
+	}
+	for _, name := range want {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+}
+
+func TestValidateAcceptsCompleteConfig(t *testing.T) {
+	cfg := Default()
+	cfg.Database.User = "app"
+	cfg.Database.Name = "app"
+	cfg.JWT.Secret = "secret"
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
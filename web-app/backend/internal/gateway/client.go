@@ -0,0 +1,426 @@
+/**
+ * client.go - Gateway Connection
+ *
+ * Each WebSocket connection is served by two goroutines:
+ * - readPump: reads client frames (IDENTIFY, RESUME, HEARTBEAT) and enforces heartbeats
+ * - writePump: the only goroutine that writes to the socket
+ *
+ * Outbound frames are queued on a bounded channel. If the queue is full the
+ * client is considered a slow consumer and is disconnected; the hub never
+ * waits on an individual connection.
+ *
+ * Events are sequenced and buffered by the connection's Session, not the
+ * connection itself, so they can be replayed after a reconnect.
+ */
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/user/web-app/internal/middleware"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second                   // Interval advertised in HELLO
+	heartbeatTimeout  = heartbeatInterval + 15*time.Second // Grace period before a missed heartbeat disconnects
+	identifyTimeout   = 10 * time.Second                   // Time allowed between connect and IDENTIFY
+	writeTimeout      = 10 * time.Second                   // Maximum time for a single socket write
+	maxFrameSize      = 4096                               // Largest client frame accepted (bytes)
+	sendBufferSize    = 2 * replayBufferSize               // Outbound frames buffered per connection (fits a full replay)
+)
+
+/**
+ * newUpgrader - Returns an upgrader accepting the frontend origin only
+ *
+ * Non-browser clients that send no Origin header are allowed. With an
+ * empty origin no browser can connect.
+ *
+ * @param origin Frontend origin, e.g. https://chat.example.com
+ */
+func newUpgrader(origin string) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			o := r.Header.Get("Origin")
+			return o == "" || o == origin
+		},
+	}
+}
+
+/**
+ * Client - A single gateway WebSocket connection
+ */
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+
+	send      chan []byte        // Outbound frames, drained by writePump
+	done      chan struct{}      // Closed when the connection should shut down
+	ctx       context.Context    // Cancelled with done, aborting the connection's queries
+	cancel    context.CancelFunc // Cancels ctx
+	closeOnce sync.Once
+	closeCode int    // Close frame code, set once before done is closed
+	closeText string // Close frame reason
+
+	userID      int         // Set on IDENTIFY/RESUME
+	session     *Session    // Set on IDENTIFY/RESUME, only by readPump
+	authID      string      // Login session of the token, set with session
+	expiry      *time.Timer // Closes the connection when the token expires, owned by readPump
+	cookieToken string      // access_token cookie sent with the upgrade request, if any
+}
+
+/**
+ * ServeWS - HTTP handler for the /gateway endpoint
+ *
+ * Upgrades the request to a WebSocket, sends HELLO and starts the
+ * connection's read and write loops. Authentication happens afterwards
+ * via the IDENTIFY opcode, since browsers cannot set an Authorization
+ * header on WebSocket requests. Clients using cookie authentication send
+ * IDENTIFY and RESUME without a token; the access_token cookie from the
+ * upgrade request is used instead (the upgrader only accepts the
+ * frontend's origin, so other sites cannot borrow the cookie).
+ */
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		log.Printf("Gateway: upgrade failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		hub:         h,
+		conn:        conn,
+		send:        make(chan []byte, sendBufferSize),
+		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		cookieToken: middleware.CookieToken(r),
+	}
+	if !h.track(c) {
+		cancel()
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server shutting down")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeTimeout))
+		conn.Close()
+		return
+	}
+
+	go c.writePump()
+
+	hello, _ := encodePayload(OpHello, "", HelloData{HeartbeatInterval: heartbeatInterval.Milliseconds()})
+	c.enqueue(hello)
+
+	go c.readPump()
+}
+
+/**
+ * enqueue - Queues a frame for delivery without blocking
+ *
+ * Disconnects the client if its buffer is full.
+ *
+ * @param frame Encoded JSON frame
+ */
+func (c *Client) enqueue(frame []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	select {
+	case c.send <- frame:
+	default:
+		log.Printf("Gateway: disconnecting slow consumer (user %d)", c.userID)
+		c.close(CloseSlowConsumer, "Slow consumer")
+	}
+}
+
+/**
+ * close - Asks writePump to send a close frame and shut the socket
+ *
+ * Only the first call has any effect.
+ *
+ * @param code WebSocket close code
+ * @param text Close reason
+ */
+func (c *Client) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.done)
+		c.cancel()
+	})
+}
+
+/**
+ * readPump - Reads and handles frames sent by the client
+ *
+ * Runs until the socket errors or the client is closed. The read deadline
+ * doubles as the heartbeat timer: it is extended on every HEARTBEAT.
+ * Each HEARTBEAT also re-checks that the token's login session is still
+ * active. On exit the session is detached; it stays resumable unless the
+ * client closed the socket with a normal close frame.
+ */
+func (c *Client) readPump() {
+	intentional := false
+	defer func() {
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
+		c.hub.disconnect(c, intentional)
+		c.close(websocket.CloseNormalClosure, "")
+	}()
+
+	c.conn.SetReadLimit(maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(identifyTimeout))
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				c.close(CloseSessionTimeout, "Heartbeat timed out")
+			}
+			intentional = websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+			return
+		}
+
+		var payload Payload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			c.close(CloseDecodeError, "Invalid payload")
+			return
+		}
+
+		identified := c.session != nil
+
+		switch payload.Op {
+		case OpHeartbeat:
+			if !identified {
+				c.close(CloseNotAuthenticated, "Not authenticated")
+				return
+			}
+			// Heartbeat data is the last sequence the client processed
+			var seq int64
+			if len(payload.Data) > 0 && json.Unmarshal(payload.Data, &seq) == nil && seq > 0 {
+				c.session.ack(seq)
+			}
+			if !c.checkSession() {
+				return
+			}
+			c.conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+			ack, _ := encodePayload(OpHeartbeatAck, "", nil)
+			c.enqueue(ack)
+
+		case OpIdentify, OpResume:
+			if identified {
+				c.close(CloseAlreadyAuthenticated, "Already authenticated")
+				return
+			}
+			var ok bool
+			if payload.Op == OpIdentify {
+				ok = c.identify(payload.Data)
+			} else {
+				ok = c.resume(payload.Data)
+			}
+			if !ok {
+				return
+			}
+			if c.session != nil {
+				c.conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+			} else {
+				// Resume was rejected; give the client time to IDENTIFY
+				c.conn.SetReadDeadline(time.Now().Add(identifyTimeout))
+			}
+
+		default:
+			if !identified {
+				c.close(CloseNotAuthenticated, "Not authenticated")
+			} else {
+				c.close(CloseUnknownOpcode, "Unknown opcode")
+			}
+			return
+		}
+	}
+}
+
+/**
+ * identify - Authenticates the connection and starts a new session
+ *
+ * Validates the JWT with the same rules as the REST middleware, loads the
+ * user's servers and sends READY as the session's first dispatch.
+ *
+ * @param data Raw IDENTIFY data
+ * @return true if the client is now identified
+ */
+func (c *Client) identify(data json.RawMessage) bool {
+	var identify IdentifyData
+	if err := json.Unmarshal(data, &identify); err != nil {
+		c.close(CloseDecodeError, "Invalid identify payload")
+		return false
+	}
+
+	claims, err := c.hub.auth.ParseToken(c.ctx, c.token(identify.Token))
+	if err != nil {
+		log.Printf("Gateway: identify failed: %v", err)
+		c.close(CloseAuthenticationFailed, "Authentication failed")
+		return false
+	}
+
+	serverIDs, err := c.hub.servers.GetServerIDsForUser(c.ctx, claims.UserID)
+	if err != nil {
+		log.Printf("Gateway: failed to load servers for user %d: %v", claims.UserID, err)
+		c.close(websocket.CloseInternalServerErr, "Failed to load servers")
+		return false
+	}
+
+	session := newSession(claims.UserID, claims.Username)
+	c.userID = claims.UserID
+	session.attach(c, 0, claims.SessionID())
+	c.session = session
+	c.expireWith(claims)
+
+	// Dispatch READY before registering so it is always sequence 1
+	ready, _ := json.Marshal(ReadyData{
+		SessionID: session.id,
+		UserID:    session.userID,
+		Username:  session.username,
+		ServerIDs: serverIDs,
+	})
+	session.dispatch(EventReady, ready)
+	c.hub.register(session, serverIDs)
+
+	log.Printf("Gateway: user %s (ID: %d) identified, session %s", session.username, session.userID, session.id)
+	return true
+}
+
+/**
+ * expireWith - Closes the connection when its access token expires
+ *
+ * The close uses CloseTokenExpired and leaves the session resumable, so
+ * the client can refresh its token and RESUME without missing events.
+ *
+ * @param claims Claims of the token the connection authenticated with
+ */
+func (c *Client) expireWith(claims *middleware.UserClaims) {
+	c.authID = claims.SessionID()
+	if claims.ExpiresAt == nil {
+		return
+	}
+	c.expiry = time.AfterFunc(time.Until(claims.ExpiresAt.Time), func() {
+		c.close(CloseTokenExpired, "Token expired")
+	})
+}
+
+/**
+ * checkSession - Closes the connection if its login session was revoked
+ *
+ * Catches revocations made by other server processes, which cannot call
+ * Hub.EndAuthSession here. Lookup errors are logged and retried on the
+ * next heartbeat rather than dropping every connection during a database
+ * outage.
+ *
+ * @return false if the connection was closed
+ */
+func (c *Client) checkSession() bool {
+	err := c.hub.auth.CheckSession(c.ctx, c.authID)
+	if err == nil {
+		return true
+	}
+	if err != middleware.ErrSessionRevoked && err != middleware.ErrNoSession {
+		log.Printf("Gateway: failed to check session for user %d: %v", c.userID, err)
+		return true
+	}
+	log.Printf("Gateway: session %s for user %d was revoked", c.session.id, c.userID)
+	c.close(CloseSessionRevoked, "Session revoked")
+	c.hub.remove(c.session)
+	return false
+}
+
+/**
+ * token - Returns the token sent in a payload, or else the cookie token
+ */
+func (c *Client) token(payloadToken string) string {
+	if payloadToken != "" {
+		return payloadToken
+	}
+	return c.cookieToken
+}
+
+/**
+ * resume - Reattaches the connection to an existing session
+ *
+ * The token must belong to the session's user. On success every buffered
+ * dispatch after the client's sequence is replayed, followed by RESUMED.
+ * If the session is gone or the gap can no longer be filled the client
+ * receives INVALID_SESSION and may IDENTIFY on the same connection.
+ *
+ * @param data Raw RESUME data
+ * @return false if the connection was closed
+ */
+func (c *Client) resume(data json.RawMessage) bool {
+	var resume ResumeData
+	if err := json.Unmarshal(data, &resume); err != nil {
+		c.close(CloseDecodeError, "Invalid resume payload")
+		return false
+	}
+
+	claims, err := c.hub.auth.ParseToken(c.ctx, c.token(resume.Token))
+	if err != nil {
+		log.Printf("Gateway: resume failed: %v", err)
+		c.close(CloseAuthenticationFailed, "Authentication failed")
+		return false
+	}
+
+	session := c.hub.lookup(resume.SessionID)
+	c.userID = claims.UserID
+	if session == nil || session.userID != claims.UserID || !session.attach(c, resume.Seq, claims.SessionID()) {
+		log.Printf("Gateway: session %s for user %d cannot be resumed", resume.SessionID, claims.UserID)
+		invalid, _ := encodePayload(OpInvalidSession, "", false)
+		c.enqueue(invalid)
+		return true
+	}
+	c.session = session
+	c.expireWith(claims)
+
+	resumed, _ := encodePayload(OpDispatch, EventResumed, nil)
+	c.enqueue(resumed)
+
+	log.Printf("Gateway: user %d resumed session %s from sequence %d", session.userID, session.id, resume.Seq)
+	return true
+}
+
+/**
+ * writePump - Writes queued frames to the socket
+ *
+ * The only goroutine allowed to write to the connection. When the client
+ * is closed it sends a close frame with the recorded code and closes the
+ * socket, which also unblocks readPump.
+ */
+func (c *Client) writePump() {
+	defer c.hub.untrack(c)
+	defer c.conn.Close()
+
+	for {
+		select {
+		case frame := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+			c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeTimeout))
+			return
+		}
+	}
+}
@@ -0,0 +1,512 @@
+/**
+ * hub.go - In-process Event Hub
+ *
+ * The Hub tracks every gateway session and which servers each session is
+ * subscribed to. REST handlers call Publish after a successful write, and
+ * the hub fans the event out to every subscribed session without blocking:
+ * each session sequences and buffers the event, then hands it to its
+ * connection's bounded outbound queue. A connection whose queue is full is
+ * disconnected rather than slowing everyone else down.
+ *
+ * Channel-scoped events go through PublishChannel instead, which asks the
+ * ChannelAuthorizer which subscribed users can view the channel and only
+ * delivers to them, so private channels never leak over the gateway.
+ * Events for DMs, which have no server, go to their participants through
+ * PublishUsers.
+ *
+ * Sessions survive their connection for resumeWindow so a client that
+ * drops briefly can RESUME and receive what it missed.
+ *
+ * On server shutdown, Shutdown sends every connection a Going Away close
+ * frame; http.Server.Shutdown does not touch upgraded connections.
+ *
+ * Usage:
+ * hub := gateway.NewHub(models.NewServerService(db), models.NewOverwriteService(db), auth, cfg.FrontendOrigin())
+ * mux.HandleFunc("/gateway", hub.ServeWS)
+ * hub.Publish(gateway.EventServerUpdate, serverID, server)
+ * hub.PublishChannel(gateway.EventMessageCreate, serverID, channelID, message)
+ */
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/user/web-app/internal/middleware"
+)
+
+/**
+ * ServerLister - Source of a user's server memberships
+ *
+ * Implemented by models.ServerService; used on IDENTIFY to decide which
+ * server events a new session should receive.
+ */
+type ServerLister interface {
+	GetServerIDsForUser(ctx context.Context, userID int) ([]int, error)
+}
+
+/**
+ * ChannelAuthorizer - Source of channel visibility
+ *
+ * Implemented by models.OverwriteService; used by PublishChannel to
+ * filter channel events down to users who can view the channel.
+ */
+type ChannelAuthorizer interface {
+	ChannelViewers(ctx context.Context, channelID int, userIDs []int) (map[int]bool, error)
+}
+
+/**
+ * Hub - Registry of gateway sessions and their subscriptions
+ */
+type Hub struct {
+	servers  ServerLister              // Membership lookup used on IDENTIFY
+	channels ChannelAuthorizer         // Visibility lookup for channel events
+	auth     *middleware.Authenticator // Verifies IDENTIFY/RESUME tokens
+	upgrader websocket.Upgrader        // Accepts the frontend origin only
+
+	mu       sync.RWMutex
+	sessions map[string]*Session           // Session ID -> session
+	byServer map[int]map[*Session]struct{} // Server ID -> subscribed sessions
+	byUser   map[int]map[*Session]struct{} // User ID -> that user's sessions
+
+	clients      map[*Client]struct{} // Open connections, identified or not
+	connections  sync.WaitGroup       // One per entry in clients
+	shuttingDown bool                 // Set by Shutdown; new connections are refused
+}
+
+/**
+ * NewHub - Constructor for Hub
+ *
+ * @param servers Membership lookup for newly identified sessions
+ * @param channels Visibility lookup for channel-scoped events
+ * @param auth Token verification shared with the REST API
+ * @param origin Frontend origin allowed to open browser connections
+ * @return Empty Hub ready to accept connections
+ */
+func NewHub(servers ServerLister, channels ChannelAuthorizer, auth *middleware.Authenticator, origin string) *Hub {
+	return &Hub{
+		servers:  servers,
+		channels: channels,
+		auth:     auth,
+		upgrader: newUpgrader(origin),
+		sessions: make(map[string]*Session),
+		byServer: make(map[int]map[*Session]struct{}),
+		byUser:   make(map[int]map[*Session]struct{}),
+		clients:  make(map[*Client]struct{}),
+	}
+}
+
+/**
+ * Shutdown - Closes every gateway connection
+ *
+ * Each client gets a Going Away close frame so it reconnects to another
+ * instance. Connections opened afterwards are refused.
+ *
+ * @param ctx Bounds how long to wait for the close frames to be written
+ * @return ctx's error if connections were still closing when it ended
+ */
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.shuttingDown = true
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.close(websocket.CloseGoingAway, "Server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.connections.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/**
+ * Publish - Sends an event to every session subscribed to a server
+ *
+ * Never blocks on slow connections. Safe to call from any goroutine.
+ * Use PublishChannel for events that belong to a single channel.
+ *
+ * @param eventType One of the Event* constants
+ * @param serverID Server the event belongs to
+ * @param data Event data, marshalled to JSON once for all recipients
+ */
+func (h *Hub) Publish(eventType string, serverID int, data interface{}) {
+	h.publish(eventType, serverID, nil, data)
+}
+
+/**
+ * PublishChannel - Sends a channel event to subscribed users who can view it
+ *
+ * @param eventType One of the Event* constants
+ * @param serverID Server the channel belongs to
+ * @param channelID Channel the event belongs to
+ * @param data Event data, marshalled to JSON once for all recipients
+ */
+func (h *Hub) PublishChannel(eventType string, serverID, channelID int, data interface{}) {
+	h.PublishTo(eventType, serverID, h.ChannelAudience(serverID, channelID), data)
+}
+
+/**
+ * ChannelAudience - Resolves which subscribed users can view a channel
+ *
+ * Handlers that delete a channel resolve the audience first and pass it
+ * to PublishTo afterwards, since the channel's overwrites are gone by
+ * then. Lookup errors are logged and yield an empty audience so events
+ * fail closed.
+ *
+ * @param serverID Server the channel belongs to
+ * @param channelID Channel to check
+ * @return Set of user IDs allowed to receive the channel's events
+ */
+func (h *Hub) ChannelAudience(serverID, channelID int) map[int]bool {
+	h.mu.RLock()
+	seen := make(map[int]bool)
+	var userIDs []int
+	for s := range h.byServer[serverID] {
+		if !seen[s.userID] {
+			seen[s.userID] = true
+			userIDs = append(userIDs, s.userID)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	// Not tied to the request that triggered the event: its write has
+	// already happened, so the event must go out even if that client left
+	viewers, err := h.channels.ChannelViewers(context.Background(), channelID, userIDs)
+	if err != nil {
+		log.Printf("Gateway: failed to resolve viewers of channel %d: %v", channelID, err)
+		return nil
+	}
+	return viewers
+}
+
+/**
+ * PublishTo - Sends a server event to a subset of its subscribed users
+ *
+ * @param eventType One of the Event* constants
+ * @param serverID Server the event belongs to
+ * @param userIDs Users who may receive the event (nil delivers to nobody)
+ * @param data Event data, marshalled to JSON once for all recipients
+ */
+func (h *Hub) PublishTo(eventType string, serverID int, userIDs map[int]bool, data interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+	h.publish(eventType, serverID, userIDs, data)
+}
+
+/**
+ * PublishUsers - Sends an event to every session of the given users
+ *
+ * Used for DM channels, which are not part of any server.
+ *
+ * @param eventType One of the Event* constants
+ * @param userIDs Users who should receive the event
+ * @param data Event data, marshalled to JSON once for all recipients
+ */
+func (h *Hub) PublishUsers(eventType string, userIDs []int, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Gateway: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, id := range userIDs {
+		for s := range h.byUser[id] {
+			s.dispatch(eventType, raw)
+		}
+	}
+}
+
+/**
+ * publish - Encodes an event and dispatches it to a server's sessions
+ *
+ * @param userIDs If non-nil, only sessions of these users receive the event
+ */
+func (h *Hub) publish(eventType string, serverID int, userIDs map[int]bool, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Gateway: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.byServer[serverID] {
+		if userIDs == nil || userIDs[s.userID] {
+			s.dispatch(eventType, raw)
+		}
+	}
+}
+
+/**
+ * Subscribe - Starts delivering a server's events to all of a user's sessions
+ *
+ * Called when a user creates or joins a server while connected.
+ *
+ * @param userID User to subscribe
+ * @param serverID Server whose events should be delivered
+ */
+func (h *Hub) Subscribe(userID, serverID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.byUser[userID] {
+		h.subscribeLocked(s, serverID)
+	}
+}
+
+/**
+ * Unsubscribe - Stops delivering a server's events to a user's sessions
+ *
+ * Called when a user leaves or is removed from a server.
+ *
+ * @param userID User to unsubscribe
+ * @param serverID Server whose events should stop
+ */
+func (h *Hub) Unsubscribe(userID, serverID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.byUser[userID] {
+		delete(s.servers, serverID)
+		delete(h.byServer[serverID], s)
+	}
+	if len(h.byServer[serverID]) == 0 {
+		delete(h.byServer, serverID)
+	}
+}
+
+/**
+ * RemoveServer - Drops every subscription to a deleted server
+ *
+ * @param serverID Server that no longer exists
+ */
+func (h *Hub) RemoveServer(serverID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.byServer[serverID] {
+		delete(s.servers, serverID)
+	}
+	delete(h.byServer, serverID)
+}
+
+/**
+ * Check - Readiness check; fails once Shutdown has been called
+ */
+func (h *Hub) Check(ctx context.Context) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.shuttingDown {
+		return errors.New("shutting down")
+	}
+	return nil
+}
+
+/**
+ * track - Records an open connection until untrack
+ *
+ * @return false if the hub is shutting down and c should be closed
+ */
+func (h *Hub) track(c *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shuttingDown {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	h.connections.Add(1)
+	return true
+}
+
+/**
+ * untrack - Forgets a connection once its socket is closed
+ */
+func (h *Hub) untrack(c *Client) {
+	h.mu.Lock()
+	_, ok := h.clients[c]
+	delete(h.clients, c)
+	h.mu.Unlock()
+	if ok {
+		h.connections.Done()
+	}
+}
+
+/**
+ * register - Adds a new session to the hub
+ *
+ * Broadcasts an online presence update if this is the user's first session.
+ *
+ * @param s Newly identified session
+ * @param serverIDs Servers the session should receive events for
+ */
+func (h *Hub) register(s *Session, serverIDs []int) {
+	h.mu.Lock()
+	firstSession := len(h.byUser[s.userID]) == 0
+	h.sessions[s.id] = s
+	if h.byUser[s.userID] == nil {
+		h.byUser[s.userID] = make(map[*Session]struct{})
+	}
+	h.byUser[s.userID][s] = struct{}{}
+	for _, id := range serverIDs {
+		h.subscribeLocked(s, id)
+	}
+	h.mu.Unlock()
+
+	if firstSession {
+		h.publishPresence(s.userID, serverIDs, "online")
+	}
+}
+
+/**
+ * lookup - Finds a live session by ID
+ *
+ * @param sessionID ID from READY
+ * @return Session, or nil if it expired or never existed
+ */
+func (h *Hub) lookup(sessionID string) *Session {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sessions[sessionID]
+}
+
+/**
+ * disconnect - Handles a connection going away
+ *
+ * If the client closed the socket deliberately the session ends now;
+ * otherwise it stays resumable for resumeWindow.
+ *
+ * @param c Connection that closed
+ * @param intentional Whether the client sent a normal close frame
+ */
+func (h *Hub) disconnect(c *Client, intentional bool) {
+	s := c.session
+	if s == nil {
+		return
+	}
+
+	generation, ok := s.detach(c)
+	if !ok {
+		return
+	}
+
+	if intentional {
+		h.remove(s)
+		return
+	}
+
+	time.AfterFunc(resumeWindow, func() {
+		if s.stillDetached(generation) {
+			log.Printf("Gateway: session %s for user %d expired", s.id, s.userID)
+			h.remove(s)
+		}
+	})
+}
+
+/**
+ * EndAuthSession - Ends gateway sessions opened with a revoked login session
+ *
+ * Their connections are closed with CloseSessionRevoked and the sessions
+ * are removed, so they cannot be resumed either. Call whenever a login
+ * session is revoked; revocations made by other processes are noticed on
+ * the next heartbeat instead.
+ *
+ * @param authID Revoked session ID (the jti claim of its access tokens)
+ */
+func (h *Hub) EndAuthSession(authID string) {
+	if authID == "" {
+		return
+	}
+
+	h.mu.RLock()
+	var ended []*Session
+	for _, s := range h.sessions {
+		if s.authenticatedWith(authID) {
+			ended = append(ended, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range ended {
+		log.Printf("Gateway: ending session %s for user %d, login session revoked", s.id, s.userID)
+		s.closeClient(CloseSessionRevoked, "Session revoked")
+		h.remove(s)
+	}
+}
+
+/**
+ * remove - Deletes a session and its subscriptions
+ *
+ * Broadcasts an offline presence update if this was the user's last session.
+ *
+ * @param s Session to remove
+ */
+func (h *Hub) remove(s *Session) {
+	h.mu.Lock()
+	if h.sessions[s.id] != s {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.sessions, s.id)
+
+	serverIDs := make([]int, 0, len(s.servers))
+	for id := range s.servers {
+		serverIDs = append(serverIDs, id)
+		delete(h.byServer[id], s)
+		if len(h.byServer[id]) == 0 {
+			delete(h.byServer, id)
+		}
+	}
+
+	delete(h.byUser[s.userID], s)
+	lastSession := len(h.byUser[s.userID]) == 0
+	if lastSession {
+		delete(h.byUser, s.userID)
+	}
+	h.mu.Unlock()
+
+	if lastSession {
+		h.publishPresence(s.userID, serverIDs, "offline")
+	}
+}
+
+/**
+ * subscribeLocked - Subscribes a single session; caller holds h.mu
+ */
+func (h *Hub) subscribeLocked(s *Session, serverID int) {
+	if h.byServer[serverID] == nil {
+		h.byServer[serverID] = make(map[*Session]struct{})
+	}
+	h.byServer[serverID][s] = struct{}{}
+	s.servers[serverID] = struct{}{}
+}
+
+/**
+ * publishPresence - Broadcasts a user's presence to each of their servers
+ */
+func (h *Hub) publishPresence(userID int, serverIDs []int, status string) {
+	for _, id := range serverIDs {
+		h.Publish(EventPresenceUpdate, id, PresenceData{UserID: userID, Status: status})
+	}
+}
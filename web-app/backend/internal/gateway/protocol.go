@@ -0,0 +1,148 @@
+/**
+ * protocol.go - WebSocket Gateway Protocol
+ *
+ * Defines the wire format spoken over /gateway. Every frame is a JSON
+ * payload with an opcode; dispatch frames (op 0) also carry an event type
+ * and the event data.
+ *
+ * Connection Lifecycle:
+ * 1. Client connects to /gateway
+ * 2. Server sends HELLO with the heartbeat interval
+ * 3. Client sends IDENTIFY with the same JWT used for REST requests
+ * 4. Server replies with a READY dispatch and starts delivering events
+ * 5. Client sends HEARTBEAT every heartbeat_interval ms, server replies HEARTBEAT_ACK
+ *
+ * Every dispatch carries a per-session sequence number "s". Heartbeats
+ * carry the last sequence the client processed, which acknowledges it.
+ * After a dropped connection the client can reconnect and send RESUME
+ * instead of IDENTIFY (see session.go); if the session cannot be resumed
+ * the server sends INVALID_SESSION and the client must IDENTIFY again.
+ *
+ * A client that misses heartbeats or cannot keep up with its event stream
+ * is disconnected with one of the close codes below.
+ */
+
+package gateway
+
+import "encoding/json"
+
+// Gateway opcodes
+const (
+	OpDispatch       = 0  // Server -> client: an event (see Event* constants)
+	OpHeartbeat      = 1  // Client -> server: keep the connection alive
+	OpIdentify       = 2  // Client -> server: authenticate with a JWT
+	OpResume         = 6  // Client -> server: resume a dropped session
+	OpInvalidSession = 9  // Server -> client: session cannot be resumed, IDENTIFY again
+	OpHello          = 10 // Server -> client: sent on connect with heartbeat interval
+	OpHeartbeatAck   = 11 // Server -> client: acknowledges a heartbeat
+)
+
+// Dispatch event types
+const (
+	EventReady           = "READY"
+	EventResumed         = "RESUMED"
+	EventMessageCreate   = "MESSAGE_CREATE"
+	EventMessageUpdate   = "MESSAGE_UPDATE"
+	EventMessageDelete   = "MESSAGE_DELETE"
+	EventChannelCreate   = "CHANNEL_CREATE"
+	EventChannelUpdate   = "CHANNEL_UPDATE"
+	EventChannelDelete   = "CHANNEL_DELETE"
+	EventRecipientAdd    = "CHANNEL_RECIPIENT_ADD"
+	EventRecipientRemove = "CHANNEL_RECIPIENT_REMOVE"
+	EventServerCreate    = "SERVER_CREATE"
+	EventServerUpdate    = "SERVER_UPDATE"
+	EventServerDelete    = "SERVER_DELETE"
+	EventMemberAdd       = "SERVER_MEMBER_ADD"
+	EventMemberRemove    = "SERVER_MEMBER_REMOVE"
+	EventMemberUpdate    = "SERVER_MEMBER_UPDATE"
+	EventRoleCreate      = "SERVER_ROLE_CREATE"
+	EventRoleUpdate      = "SERVER_ROLE_UPDATE"
+	EventRoleDelete      = "SERVER_ROLE_DELETE"
+	EventPresenceUpdate  = "PRESENCE_UPDATE"
+	EventUserUpdate      = "USER_UPDATE"
+)
+
+// WebSocket close codes sent by the gateway
+const (
+	CloseUnknownOpcode        = 4001 // Client sent an opcode the server does not accept
+	CloseDecodeError          = 4002 // Client sent a payload that is not valid JSON
+	CloseNotAuthenticated     = 4003 // Client sent a payload before IDENTIFY
+	CloseAuthenticationFailed = 4004 // IDENTIFY token was invalid or expired
+	CloseAlreadyAuthenticated = 4005 // Client sent IDENTIFY twice
+	CloseSessionTimeout       = 4009 // Client stopped sending heartbeats
+	CloseSlowConsumer         = 4010 // Client's outbound buffer overflowed
+	CloseSessionResumed       = 4011 // The session was resumed on another connection
+	CloseSessionRevoked       = 4012 // The login session behind the token was revoked
+	CloseTokenExpired         = 4013 // The access token expired; RESUME with a fresh one
+)
+
+/**
+ * Payload - Envelope for every gateway frame
+ */
+type Payload struct {
+	Op   int             `json:"op"`          // Opcode
+	Data json.RawMessage `json:"d,omitempty"` // Opcode-specific data
+	Seq  int64           `json:"s,omitempty"` // Sequence number (dispatch only)
+	Type string          `json:"t,omitempty"` // Event type (dispatch only)
+}
+
+/**
+ * HelloData - Data for OpHello
+ */
+type HelloData struct {
+	HeartbeatInterval int64 `json:"heartbeat_interval"` // Milliseconds between client heartbeats
+}
+
+/**
+ * IdentifyData - Data for OpIdentify
+ */
+type IdentifyData struct {
+	Token string `json:"token"` // JWT issued by the auth endpoints; omitted in cookie mode
+}
+
+/**
+ * ResumeData - Data for OpResume
+ */
+type ResumeData struct {
+	Token     string `json:"token"`      // JWT for the same user that created the session; omitted in cookie mode
+	SessionID string `json:"session_id"` // Session ID from READY
+	Seq       int64  `json:"seq"`        // Last sequence number the client processed
+}
+
+/**
+ * ReadyData - Data for the READY dispatch
+ */
+type ReadyData struct {
+	SessionID string `json:"session_id"` // ID to use with RESUME
+	UserID    int    `json:"user_id"`    // Authenticated user ID
+	Username  string `json:"username"`   // Authenticated username
+	ServerIDs []int  `json:"server_ids"` // Servers whose events will be delivered
+}
+
+/**
+ * PresenceData - Data for the PRESENCE_UPDATE dispatch
+ */
+type PresenceData struct {
+	UserID int    `json:"user_id"` // User whose presence changed
+	Status string `json:"status"`  // "online" or "offline"
+}
+
+/**
+ * encodePayload - Marshals a payload with the given opcode and data
+ *
+ * @param op Opcode
+ * @param eventType Event type (empty for non-dispatch frames)
+ * @param data Opcode-specific data (may be nil)
+ * @return Encoded JSON frame
+ */
+func encodePayload(op int, eventType string, data interface{}) ([]byte, error) {
+	payload := Payload{Op: op, Type: eventType}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		payload.Data = raw
+	}
+	return json.Marshal(payload)
+}
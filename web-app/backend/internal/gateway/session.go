@@ -0,0 +1,220 @@
+/**
+ * session.go - Resumable Gateway Sessions
+ *
+ * A Session outlives the WebSocket connection that created it. Every
+ * dispatch sent to a session gets the next sequence number and is kept in
+ * a bounded replay buffer. If the connection drops, the session stays
+ * subscribed and keeps buffering for resumeWindow; a new connection can
+ * send RESUME with the session ID and the last sequence it processed to
+ * receive exactly the events it missed.
+ *
+ * A resume fails (and the client must IDENTIFY again) when:
+ * - the session expired or was closed intentionally by the client
+ * - the events after the client's sequence have been evicted from the buffer
+ */
+
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	replayBufferSize = 256             // Dispatches kept per session for replay
+	resumeWindow     = 2 * time.Minute // How long a disconnected session can be resumed
+)
+
+/**
+ * replayEntry - A sequenced dispatch frame kept for replay
+ */
+type replayEntry struct {
+	seq   int64
+	frame []byte
+}
+
+/**
+ * Session - Per-identify event stream with sequence numbers and replay
+ */
+type Session struct {
+	id       string // Random session ID sent in READY
+	userID   int
+	username string
+
+	servers map[int]struct{} // Subscribed server IDs, guarded by hub.mu
+
+	mu         sync.Mutex
+	seq        int64                         // Last sequence number assigned
+	replay     [replayBufferSize]replayEntry // Ring buffer of recent dispatches
+	replayHead int                           // Index of the oldest entry
+	replayLen  int                           // Number of valid entries
+	client     *Client                       // Attached connection, nil while disconnected
+	authID     string                        // Login session (token jti) the attached connection authenticated with
+	generation int                           // Incremented on every detach, used to ignore stale expiry timers
+}
+
+/**
+ * newSession - Creates a session with a random ID
+ */
+func newSession(userID int, username string) *Session {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return &Session{
+		id:       hex.EncodeToString(b),
+		userID:   userID,
+		username: username,
+		servers:  make(map[int]struct{}),
+	}
+}
+
+/**
+ * dispatch - Sequences an event, buffers it and delivers it if connected
+ *
+ * @param eventType One of the Event* constants
+ * @param data Pre-encoded event data
+ */
+func (s *Session) dispatch(eventType string, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	frame, err := json.Marshal(Payload{Op: OpDispatch, Type: eventType, Seq: s.seq, Data: data})
+	if err != nil {
+		return
+	}
+
+	// Append to the ring, overwriting the oldest entry when full
+	idx := (s.replayHead + s.replayLen) % replayBufferSize
+	s.replay[idx] = replayEntry{seq: s.seq, frame: frame}
+	if s.replayLen < replayBufferSize {
+		s.replayLen++
+	} else {
+		s.replayHead = (s.replayHead + 1) % replayBufferSize
+	}
+
+	if s.client != nil {
+		s.client.enqueue(frame)
+	}
+}
+
+/**
+ * ack - Drops buffered dispatches the client has confirmed receiving
+ *
+ * @param seq Highest sequence number the client has processed
+ */
+func (s *Session) ack(seq int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.replayLen > 0 && s.replay[s.replayHead].seq <= seq {
+		s.replay[s.replayHead] = replayEntry{}
+		s.replayHead = (s.replayHead + 1) % replayBufferSize
+		s.replayLen--
+	}
+}
+
+/**
+ * attach - Binds a connection to the session
+ *
+ * When resuming, every buffered dispatch after lastSeq is queued on the
+ * connection before any new events. Fails if the requested events are no
+ * longer buffered.
+ *
+ * A connection that is still attached is replaced and closed: after a
+ * brief network drop the server may not notice the old socket died until
+ * heartbeatTimeout, and the client must not lose its replay window
+ * meanwhile. Callers check that the resuming user owns the session.
+ *
+ * @param c Connection to attach
+ * @param lastSeq Last sequence the client processed (0 for a new session)
+ * @param authID Login session of the connection's token, see Hub.EndAuthSession
+ * @return true if the connection is now attached
+ */
+func (s *Session) attach(c *Client, lastSeq int64, authID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == c || lastSeq > s.seq {
+		return false
+	}
+
+	if lastSeq < s.seq {
+		// The next event the client needs must still be in the buffer
+		if s.replayLen == 0 || s.replay[s.replayHead].seq > lastSeq+1 {
+			return false
+		}
+	}
+
+	if s.client != nil {
+		s.client.close(CloseSessionResumed, "Session resumed elsewhere")
+	}
+	s.client = c
+	s.authID = authID
+	for i := 0; i < s.replayLen; i++ {
+		entry := s.replay[(s.replayHead+i)%replayBufferSize]
+		if entry.seq > lastSeq {
+			c.enqueue(entry.frame)
+		}
+	}
+
+	return true
+}
+
+/**
+ * detach - Unbinds a connection from the session
+ *
+ * @param c Connection that disconnected
+ * @return The session generation to pass to stillDetached, and whether c was attached
+ */
+func (s *Session) detach(c *Client) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != c {
+		return 0, false
+	}
+	s.client = nil
+	s.generation++
+	return s.generation, true
+}
+
+/**
+ * stillDetached - Reports whether no connection has attached since detach
+ *
+ * @param generation Value returned by detach
+ */
+func (s *Session) stillDetached(generation int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client == nil && s.generation == generation
+}
+
+/**
+ * authenticatedWith - Reports whether the attached connection used a login session
+ *
+ * @param authID Login session ID (token jti)
+ */
+func (s *Session) authenticatedWith(authID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authID == authID
+}
+
+/**
+ * closeClient - Closes the attached connection, if any
+ *
+ * @param code WebSocket close code
+ * @param text Close reason
+ */
+func (s *Session) closeClient(code int, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		s.client.close(code, text)
+	}
+}
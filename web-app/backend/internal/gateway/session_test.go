@@ -0,0 +1,272 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// newTestClient returns a Client without a socket; frames queued on it
+// stay in send for the test to read.
+func newTestClient() *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		send:   make(chan []byte, sendBufferSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// dispatchN sends n events to the session.
+func dispatchN(s *Session, n int) {
+	for i := 0; i < n; i++ {
+		s.dispatch(EventMessageCreate, json.RawMessage(`{}`))
+	}
+}
+
+// received returns the sequence numbers of the frames queued on c.
+func received(t *testing.T, c *Client) []int64 {
+	t.Helper()
+	var seqs []int64
+	for {
+		select {
+		case frame := <-c.send:
+			var p Payload
+			if err := json.Unmarshal(frame, &p); err != nil {
+				t.Fatal(err)
+			}
+			seqs = append(seqs, p.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+// buffered returns the sequence numbers held in the replay ring, oldest first.
+func buffered(s *Session) []int64 {
+	seqs := make([]int64, s.replayLen)
+	for i := range seqs {
+		seqs[i] = s.replay[(s.replayHead+i)%replayBufferSize].seq
+	}
+	return seqs
+}
+
+// isRange reports whether seqs is exactly from..to.
+func isRange(seqs []int64, from, to int64) bool {
+	if int64(len(seqs)) != to-from+1 {
+		return false
+	}
+	for i, seq := range seqs {
+		if seq != from+int64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSessionReplayWraparound(t *testing.T) {
+	tests := []struct {
+		name     string
+		dispatch int
+		wantFrom int64
+	}{
+		{"partly filled", 10, 1},
+		{"exactly full", replayBufferSize, 1},
+		{"one past full", replayBufferSize + 1, 2},
+		{"wrapped more than once", 2*replayBufferSize + 44, replayBufferSize + 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(1, "user")
+			dispatchN(s, tt.dispatch)
+
+			if s.seq != int64(tt.dispatch) {
+				t.Fatalf("seq = %d, want %d", s.seq, tt.dispatch)
+			}
+			if got := buffered(s); !isRange(got, tt.wantFrom, s.seq) {
+				t.Fatalf("buffer holds %v, want %d..%d", got, tt.wantFrom, s.seq)
+			}
+		})
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	const dispatched = replayBufferSize + 50 // Oldest buffered event is 51
+
+	tests := []struct {
+		name     string
+		lastSeq  int64
+		ok       bool
+		wantFrom int64
+	}{
+		{"up to date", dispatched, true, 0},
+		{"missed one event", dispatched - 1, true, dispatched},
+		{"next event is the buffer tail", 50, true, 51},
+		{"next event was evicted", 49, false, 0},
+		{"new session after eviction", 0, false, 0},
+		{"sequence the session never sent", dispatched + 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(1, "user")
+			dispatchN(s, dispatched)
+
+			c := newTestClient()
+			if ok := s.attach(c, tt.lastSeq, "auth"); ok != tt.ok {
+				t.Fatalf("attach = %v, want %v", ok, tt.ok)
+			}
+			got := received(t, c)
+			if !tt.ok {
+				if s.client != nil || len(got) != 0 {
+					t.Fatalf("refused attach left client %p with %d frames", s.client, len(got))
+				}
+				return
+			}
+			if tt.wantFrom == 0 {
+				if len(got) != 0 {
+					t.Fatalf("replayed %v to an up-to-date client", got)
+				}
+				return
+			}
+			if !isRange(got, tt.wantFrom, dispatched) {
+				t.Fatalf("replayed %v, want %d..%d", got, tt.wantFrom, dispatched)
+			}
+		})
+	}
+}
+
+func TestSessionResumeEmptyBuffer(t *testing.T) {
+	s := newSession(1, "user")
+	dispatchN(s, 3)
+	s.ack(3)
+
+	// Everything was acknowledged, so only a fully caught-up client may resume
+	if s.attach(newTestClient(), 2, "auth") {
+		t.Fatal("attach succeeded although event 3 was acknowledged and dropped")
+	}
+	if !s.attach(newTestClient(), 3, "auth") {
+		t.Fatal("attach refused an up-to-date client")
+	}
+}
+
+func TestSessionAck(t *testing.T) {
+	tests := []struct {
+		name     string
+		ack      int64
+		wantFrom int64
+	}{
+		{"nothing acknowledged", 0, 1},
+		{"oldest entry only", 1, 2},
+		{"part of the buffer", 7, 8},
+		{"everything", 12, 0},
+		{"beyond the last sequence", 20, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Start wrapped so trimming crosses the end of the array
+			s := newSession(1, "user")
+			s.replayHead = replayBufferSize - 3
+			dispatchN(s, 12)
+
+			s.ack(tt.ack)
+			got := buffered(s)
+			if tt.wantFrom == 0 {
+				if len(got) != 0 {
+					t.Fatalf("buffer holds %v after acknowledging everything", got)
+				}
+				return
+			}
+			if !isRange(got, tt.wantFrom, 12) {
+				t.Fatalf("buffer holds %v, want %d..12", got, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestSessionAckThenDispatch(t *testing.T) {
+	s := newSession(1, "user")
+	dispatchN(s, replayBufferSize)
+	s.ack(replayBufferSize - 1)
+	dispatchN(s, replayBufferSize-1)
+
+	// Acknowledged slots are reused before anything unacknowledged is evicted
+	if got := buffered(s); !isRange(got, replayBufferSize, 2*replayBufferSize-1) {
+		t.Fatalf("buffer holds %d..%d, want %d..%d", got[0], got[len(got)-1], replayBufferSize, 2*replayBufferSize-1)
+	}
+}
+
+func TestSessionReplacesStaleClient(t *testing.T) {
+	s := newSession(1, "user")
+	stale := newTestClient()
+	if !s.attach(stale, 0, "old-login") {
+		t.Fatal("attach refused a new session")
+	}
+	dispatchN(s, 5)
+	if got := received(t, stale); !isRange(got, 1, 5) {
+		t.Fatalf("attached client received %v, want 1..5", got)
+	}
+
+	// The stale connection has not noticed the drop; the client resumes
+	fresh := newTestClient()
+	if !s.attach(fresh, 3, "new-login") {
+		t.Fatal("attach refused to replace a stale connection")
+	}
+
+	select {
+	case <-stale.done:
+	default:
+		t.Fatal("stale connection was not closed")
+	}
+	if stale.closeCode != CloseSessionResumed {
+		t.Fatalf("stale close code = %d, want %d", stale.closeCode, CloseSessionResumed)
+	}
+	if got := received(t, fresh); !isRange(got, 4, 5) {
+		t.Fatalf("resumed client received %v, want 4..5", got)
+	}
+	if !s.authenticatedWith("new-login") || s.authenticatedWith("old-login") {
+		t.Fatalf("session authID = %q, want new-login", s.authID)
+	}
+
+	// The stale connection's readPump exit must not detach the new one
+	if _, ok := s.detach(stale); ok {
+		t.Fatal("detach of the replaced connection succeeded")
+	}
+	dispatchN(s, 1)
+	if got := received(t, fresh); !isRange(got, 6, 6) {
+		t.Fatalf("resumed client received %v after detaching the stale one, want 6", got)
+	}
+
+	// Re-attaching the connection that is already attached is refused
+	if s.attach(fresh, 6, "new-login") {
+		t.Fatal("attach accepted the already attached connection")
+	}
+}
+
+func TestSessionDetach(t *testing.T) {
+	s := newSession(1, "user")
+	c := newTestClient()
+	s.attach(c, 0, "auth")
+
+	generation, ok := s.detach(c)
+	if !ok || !s.stillDetached(generation) {
+		t.Fatal("session is not detached after detach")
+	}
+
+	// Dispatches while detached are buffered for the next connection
+	dispatchN(s, 2)
+	if got := received(t, c); len(got) != 0 {
+		t.Fatalf("detached client received %v", got)
+	}
+
+	resumed := newTestClient()
+	if !s.attach(resumed, 0, "auth") {
+		t.Fatal("attach refused to resume a detached session")
+	}
+	if s.stillDetached(generation) {
+		t.Fatal("stillDetached is true after a new connection attached")
+	}
+	if got := received(t, resumed); !isRange(got, 1, 2) {
+		t.Fatalf("resumed client received %v, want 1..2", got)
+	}
+}
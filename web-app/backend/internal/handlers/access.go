@@ -0,0 +1,263 @@
+/**
+ * access.go - Shared permission checks for server and channel handlers
+ *
+ * Every handler that touches a server, channel or message resolves the
+ * caller's effective permissions through these helpers so access rules
+ * (and the 404-instead-of-403 behaviour for non-members) stay identical
+ * everywhere. Permission math lives in the permissions package.
+ *
+ * Channels come in two kinds: server channels, whose access comes from
+ * roles and overwrites, and DM channels, whose participants always get
+ * permissions.DirectMessage. channelAccess hides the difference.
+ */
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+/**
+ * loadPermissions - Computes a user's effective permissions in a server
+ *
+ * @param ctx Request context
+ * @param roles Role service
+ * @param serverID Server to check
+ * @param userID User to check
+ * @return The user's computed permissions in the server
+ * @return isMember Whether the user belongs to the server (owners always do)
+ * @return error sql.ErrNoRows if the server does not exist, or a database error
+ */
+func loadPermissions(ctx context.Context, roles *models.RoleService, serverID, userID int) (permissions.Result, bool, error) {
+	in, err := roles.GetPermissionInputs(ctx, serverID, userID)
+	if err != nil {
+		return permissions.Result{}, false, err
+	}
+	return permissions.Compute(in), in.IsMember, nil
+}
+
+/**
+ * requireMember - Checks that the user belongs to the server
+ *
+ * Non-members (and missing servers) get a 404 so server IDs cannot be probed.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request, whose context bounds the queries
+ * @param roles Role service
+ * @param serverID Server to check
+ * @param userID Authenticated user
+ * @return The user's effective permissions and whether the check passed
+ */
+func requireMember(w http.ResponseWriter, r *http.Request, roles *models.RoleService, serverID, userID int) (permissions.Result, bool) {
+	perms, isMember, err := loadPermissions(r.Context(), roles, serverID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to load permissions in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return perms, false
+	}
+	if !isMember {
+		writeError(w, http.StatusNotFound, "Server not found")
+		return perms, false
+	}
+	return perms, true
+}
+
+/**
+ * requirePermission - Checks that the user is a member with the given permission
+ *
+ * Non-members get 404, members without the permission get 403.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request, whose context bounds the queries
+ * @param roles Role service
+ * @param serverID Server to check
+ * @param userID Authenticated user
+ * @param perm Required permission
+ * @return The user's effective permissions and whether the check passed
+ */
+func requirePermission(w http.ResponseWriter, r *http.Request, roles *models.RoleService, serverID, userID int, perm permissions.Permission) (permissions.Result, bool) {
+	perms, ok := requireMember(w, r, roles, serverID, userID)
+	if !ok {
+		return perms, false
+	}
+	if !perms.Permissions.Has(perm) {
+		writeForbidden(w, perms, perm)
+		return perms, false
+	}
+	return perms, true
+}
+
+/**
+ * writeForbidden - Writes the 403 for a missing permission
+ *
+ * Says so when the permission was withheld because the server requires
+ * 2FA for moderation, so the user knows how to get it back.
+ *
+ * @param w HTTP response writer
+ * @param perms The user's effective permissions
+ * @param perm The permission that was required
+ */
+func writeForbidden(w http.ResponseWriter, perms permissions.Result, perm permissions.Permission) {
+	if perms.MFARestricted && perm&permissions.Moderation != 0 {
+		writeError(w, http.StatusForbidden, "This server requires two-factor authentication for moderation")
+		return
+	}
+	writeError(w, http.StatusForbidden, "You do not have permission to do that")
+}
+
+/**
+ * loadChannelPermissions - Computes a user's effective permissions in a channel
+ *
+ * @param ctx Request context
+ * @param roles Role service
+ * @param overwrites Overwrite service
+ * @param serverID Server the channel belongs to
+ * @param channelID Channel to check
+ * @param userID User to check
+ * @return The user's computed permissions in the channel
+ * @return isMember Whether the user belongs to the channel's server (owners always do)
+ * @return error sql.ErrNoRows if the server does not exist, or a database error
+ */
+func loadChannelPermissions(ctx context.Context, roles *models.RoleService, overwrites *models.OverwriteService, serverID, channelID, userID int) (permissions.Result, bool, error) {
+	in, err := roles.GetPermissionInputs(ctx, serverID, userID)
+	if err != nil {
+		return permissions.Result{}, false, err
+	}
+	channelOverwrites, err := overwrites.GetChannelOverwrites(ctx, channelID)
+	if err != nil {
+		return permissions.Result{}, false, err
+	}
+	return permissions.ComputeChannel(in, channelOverwrites), in.IsMember, nil
+}
+
+/**
+ * channelAccess - Services needed to resolve a user's access to any channel
+ */
+type channelAccess struct {
+	channels   *models.ChannelService   // Channel lookup
+	roles      *models.RoleService      // Server-level permissions
+	overwrites *models.OverwriteService // Channel permission overwrites
+	dms        *models.DMService        // DM participants
+}
+
+/**
+ * newChannelAccess - Constructor for channelAccess
+ *
+ * @param db Database connection shared by the underlying services
+ * @return Configured channelAccess instance
+ */
+func newChannelAccess(db *sql.DB) *channelAccess {
+	return &channelAccess{
+		channels:   models.NewChannelService(db),
+		roles:      models.NewRoleService(db),
+		overwrites: models.NewOverwriteService(db),
+		dms:        models.NewDMService(db),
+	}
+}
+
+/**
+ * requireMember - Loads a channel and checks the user can view it
+ *
+ * Users who are not in the channel's server (or DM), and server members
+ * without View Channels in the channel, get a 404 so channel IDs cannot
+ * be probed.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request, whose context bounds the queries
+ * @param channelID Channel to load
+ * @param userID Authenticated user
+ * @return The channel, the user's permissions in it, and whether the check passed
+ */
+func (a *channelAccess) requireMember(w http.ResponseWriter, r *http.Request, channelID, userID int) (*models.Channel, permissions.Result, bool) {
+	ctx := r.Context()
+	channel, err := a.channels.GetChannelByID(ctx, channelID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Channel not found")
+			return nil, permissions.Result{}, false
+		}
+		log.Printf("Database error for channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return nil, permissions.Result{}, false
+	}
+
+	var perms permissions.Result
+	var isMember bool
+	if channel.IsPrivate() {
+		isMember, err = a.dms.IsRecipient(ctx, channelID, userID)
+		if isMember {
+			perms.Permissions = permissions.DirectMessage
+		}
+	} else {
+		perms, isMember, err = loadChannelPermissions(ctx, a.roles, a.overwrites, channel.ServerID, channelID, userID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to load permissions in channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return nil, perms, false
+	}
+	if !isMember || !perms.Permissions.Has(permissions.ViewChannels) {
+		writeError(w, http.StatusNotFound, "Channel not found")
+		return nil, perms, false
+	}
+
+	return channel, perms, true
+}
+
+/**
+ * requirePermission - Checks that the user has a permission in a channel
+ *
+ * Users who cannot view the channel get 404, users without the
+ * permission get 403.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request, whose context bounds the queries
+ * @param channelID Channel to load
+ * @param userID Authenticated user
+ * @param perm Required permission
+ * @return The channel, the user's permissions in it, and whether the check passed
+ */
+func (a *channelAccess) requirePermission(w http.ResponseWriter, r *http.Request, channelID, userID int, perm permissions.Permission) (*models.Channel, permissions.Result, bool) {
+	channel, perms, ok := a.requireMember(w, r, channelID, userID)
+	if !ok {
+		return nil, perms, false
+	}
+	if !perms.Permissions.Has(perm) {
+		writeForbidden(w, perms, perm)
+		return nil, perms, false
+	}
+	return channel, perms, true
+}
+
+/**
+ * publish - Delivers a channel event to everyone who can see the channel
+ *
+ * Server channel events go to members who can view the channel; DM
+ * events go to the DM's participants.
+ *
+ * @param hub Gateway hub
+ * @param channel Channel the event belongs to
+ * @param eventType One of the gateway.Event* constants
+ * @param data Event data
+ */
+func (a *channelAccess) publish(hub *gateway.Hub, channel *models.Channel, eventType string, data interface{}) {
+	if !channel.IsPrivate() {
+		hub.PublishChannel(eventType, channel.ServerID, channel.ID, data)
+		return
+	}
+
+	// The write already happened, so deliver even if the client went away
+	recipients, err := a.dms.GetRecipientIDs(context.Background(), channel.ID)
+	if err != nil {
+		log.Printf("Failed to load recipients of channel %d: %v", channel.ID, err)
+		return
+	}
+	hub.PublishUsers(eventType, recipients, data)
+}
@@ -1,31 +1,44 @@
 /**
- * auth.go - Google OAuth Authentication Handler
- * 
- * This file implements the backend Google OAuth flow for the Discord clone.
- * It handles the complete OAuth 2.0 authorization code flow, user management,
- * and JWT token generation.
- * 
+ * auth.go - OAuth / OpenID Connect Authentication Handler
+ *
+ * This file implements the backend login flow for every configured login
+ * provider (Google, GitHub, GitLab and any OIDC provider; see the oauth
+ * package). It handles the OAuth 2.0 authorization code flow, user
+ * management and token generation.
+ *
  * OAuth Flow:
- * 1. User clicks "Sign in with Google" -> /auth/google/login
- * 2. Redirect to Google with authorization URL
- * 3. Google redirects back to /auth/google/callback with code
- * 4. Exchange code for access token
- * 5. Use access token to get user info from Google
- * 6. Create/find user in database
- * 7. Generate JWT token
- * 8. Redirect to frontend with token
- * 
+ * 1. User clicks "Sign in with <provider>" -> /auth/{provider}/login
+ * 2. Redirect to the provider with authorization URL
+ * 3. Provider redirects back to /auth/{provider}/callback with code
+ * 4. Exchange code for tokens and verify the ID token (OIDC)
+ * 5. Map the provider's user info to a provider-agnostic identity
+ * 6. Find the user the identity is linked to, or create one
+ * 7. Issue a single-use sign-in code, or an MFA ticket if the user has
+ *    two-factor authentication on (see mfa.go)
+ * 8. Redirect to the frontend with ?code=... (or ?mfa_ticket=...)
+ * 9. The frontend exchanges the code for tokens with POST /auth/exchange
+ *
+ * Tokens never appear in a URL, so they cannot leak through browser
+ * history, Referer headers or proxy logs. Codes are stored hashed, live
+ * for models.AuthCodeTTL and work once.
+ *
+ * The same callback completes account linking (see identity.go): a login
+ * carrying the oauth_link cookie set by IdentityHandler.StartLink links
+ * the identity to the requesting user, and an unknown identity whose verified email belongs to an existing
+ * user is handed back as a link_token to confirm rather than signed in.
+ *
  * Security Features:
  * - State parameter for CSRF protection
+ * - Nonce bound into OIDC ID tokens to prevent replay
+ * - Only provider-verified email addresses are accepted
  * - JWT tokens for stateless authentication
- * - Secure cookie handling
- * - Input validation and error handling
- * 
- * Environment Variables Required:
- * - GOOGLE_CLIENT_ID: Google OAuth client ID
- * - GOOGLE_CLIENT_SECRET: Google OAuth client secret
- * - OAUTH_REDIRECT_URL: OAuth callback URL
- * - JWT_SECRET: Secret key for JWT signing
+ * - Single-use sign-in codes instead of tokens in redirect URLs
+ *
+ * Environment Variables:
+ * - Provider credentials, e.g. GOOGLE_CLIENT_ID (see config.OAuthConfig)
+ * - OAUTH_CALLBACK_BASE_URL: Public base URL of this server
+ * - FRONTEND_URL: Base URL of the frontend the callback redirects to
+ * - JWT_SECRET or JWT_PRIVATE_KEY_FILE: JWT signing key (see tokens package)
  */
 
 package handlers
@@ -35,316 +48,499 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
-	"encoding/json"
-	"fmt"
+	"encoding/hex"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/middleware"
 	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/oauth"
+	"github.com/user/web-app/internal/tokens"
 )
 
+// maxUsernameAttempts is how many other usernames are tried when a new
+// OAuth user's username is already taken.
+const maxUsernameAttempts = 5
+
+// oauthCookieTTL bounds how long a user may take at the provider's login page
+const oauthCookieTTL = 10 * time.Minute
+
 /**
  * AuthHandler - Main authentication handler struct
- * 
- * Contains all dependencies needed for OAuth authentication:
+ *
+ * Contains all dependencies needed for authentication:
  * - userService: Database operations for user management
- * - oauthConfig: Google OAuth configuration
- * - jwtSecret: Secret key for JWT token signing
+ * - refreshTokens: Database operations for refresh token rotation
+ * - sessions: Database operations for sign-in sessions
+ * - identities: Database operations for linked provider accounts
+ * - mfa: Database operations for two-factor authentication
+ * - authCodes: Database operations for single-use sign-in codes
+ * - providers: Configured OAuth / OIDC login providers
+ * - tokens: Access token signing (shared with the middleware)
+ * - frontendURL: Base URL of the frontend the OAuth callback redirects to
  */
 type AuthHandler struct {
-	userService *models.UserService  // Database service for user operations
-	oauthConfig *oauth2.Config       // Google OAuth2 configuration
-	jwtSecret   []byte               // JWT signing secret
+	userService   *models.UserService         // Database service for user operations
+	refreshTokens *models.RefreshTokenService // Database service for refresh tokens
+	sessions      *models.SessionService      // Database service for sessions
+	identities    *models.IdentityService     // Database service for linked identities
+	mfa           *models.MFAService          // Database service for 2FA challenges
+	authCodes     *models.AuthCodeService     // Database service for sign-in codes
+	providers     *oauth.Registry             // Login providers by name
+	tokens        *tokens.Service             // Access token signing
+	auth          *middleware.Authenticator   // Auth cookies and session cache
+	frontendURL   string                      // Frontend base URL, without trailing slash
+	hub           *gateway.Hub                // Gateway connections to drop on logout
 }
 
 /**
- * GoogleUserInfo - User information returned by Google OAuth API
- * 
- * This struct maps to the JSON response from Google's userinfo endpoint.
- * Contains the essential user data we need for account creation.
+ * AuthResponse - Response structure for successful authentication
+ *
+ * Returned by the email/password endpoints and by POST /auth/exchange,
+ * which completes the OAuth flow. In cookie mode (see refresh.go) the
+ * tokens are set as cookies and only CSRFToken is returned.
  */
-type GoogleUserInfo struct {
-	ID      string `json:"id"`      // Google user ID (unique identifier)
-	Email   string `json:"email"`   // User's email address
-	Name    string `json:"name"`    // User's display name
-	Picture string `json:"picture"` // Profile picture URL
+type AuthResponse struct {
+	Token        string       `json:"token,omitempty"`         // JWT access token
+	RefreshToken string       `json:"refresh_token,omitempty"` // Opaque token for POST /auth/refresh
+	CSRFToken    string       `json:"csrf_token,omitempty"`    // Cookie mode: value for the X-CSRF-Token header
+	ExpiresIn    int          `json:"expires_in"`              // Access token lifetime in seconds
+	User         *models.User `json:"user"`                    // User information
 }
 
 /**
- * AuthResponse - Response structure for successful authentication
- * 
- * This is sent back to the frontend after successful OAuth.
- * Currently not used as we redirect with token in URL instead.
+ * ProvidersResponse - Response body for GET /auth/providers
  */
-type AuthResponse struct {
-	Token string       `json:"token"` // JWT token
-	User  *models.User `json:"user"`  // User information
+type ProvidersResponse struct {
+	Providers []string `json:"providers"` // Names usable in /auth/{provider}/login
+}
+
+/**
+ * ExchangeRequest - Request body for POST /auth/exchange
+ */
+type ExchangeRequest struct {
+	Code       string `json:"code"`        // From the ?code= parameter of the callback redirect
+	DeviceName string `json:"device_name"` // Optional label for the new session
 }
 
 /**
  * NewAuthHandler - Constructor for AuthHandler
- * 
- * Initializes the authentication handler with all required dependencies.
- * Sets up Google OAuth configuration using environment variables.
- * 
+ *
  * @param db Database connection for user operations
+ * @param tokenService Signs access tokens (see config.JWTConfig)
+ * @param auth Token verification and auth cookies shared with JWTMiddleware
+ * @param providers Login providers (see config.OAuthConfig)
+ * @param frontendURL Base URL of the frontend, e.g. http://localhost:5173
+ * @param hub Gateway hub whose connections end with their session
  * @return Configured AuthHandler instance
  */
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	// Initialize user service for database operations
-	userService := models.NewUserService(db)
-	
-	// Configure Google OAuth2 settings
-	// These values come from Google Cloud Console OAuth credentials
-	oauthConfig := &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),     // Public client identifier
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"), // Secret key (keep secure!)
-		RedirectURL:  os.Getenv("OAUTH_REDIRECT_URL"),   // Where Google redirects after auth
-		Scopes:       []string{"openid", "profile", "email"}, // What data we want from Google
-		Endpoint:     google.Endpoint,                   // Google's OAuth endpoints
-	}
-	
-	// JWT secret for signing tokens (must be secure and consistent)
-	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-	
+func NewAuthHandler(db *sql.DB, tokenService *tokens.Service, auth *middleware.Authenticator, providers *oauth.Registry, frontendURL string, hub *gateway.Hub) *AuthHandler {
 	return &AuthHandler{
-		userService: userService,
-		oauthConfig: oauthConfig,
-		jwtSecret:   jwtSecret,
+		userService:   models.NewUserService(db),
+		refreshTokens: models.NewRefreshTokenService(db),
+		sessions:      models.NewSessionService(db),
+		identities:    models.NewIdentityService(db),
+		mfa:           models.NewMFAService(db),
+		authCodes:     models.NewAuthCodeService(db),
+		providers:     providers,
+		tokens:        tokenService,
+		auth:          auth,
+		frontendURL:   strings.TrimSuffix(frontendURL, "/"),
+		hub:           hub,
 	}
 }
 
 /**
- * GoogleLogin - Initiates Google OAuth flow
- * 
+ * ListProviders - Lists the login providers the frontend can offer
+ */
+func (h *AuthHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ProvidersResponse{Providers: h.providers.Names()})
+}
+
+/**
+ * OAuthLogin - Initiates the OAuth flow for {provider}
+ *
  * This endpoint starts the OAuth process by:
- * 1. Generating a random state parameter for CSRF protection
- * 2. Creating the Google authorization URL
- * 3. Setting a secure cookie with the state
- * 4. Redirecting the user to Google's login page
- * 
- * Security Notes:
- * - State parameter prevents CSRF attacks
- * - HttpOnly cookie prevents XSS attacks
- * - Short expiration (10 min) limits exposure window
- * 
- * Flow: Frontend -> /auth/google/login -> Google OAuth -> /auth/google/callback
+ * 1. Generating random state (CSRF) and nonce (ID token replay) values
+ * 2. Creating the provider's authorization URL
+ * 3. Setting short-lived cookies with the state and nonce
+ * 4. Redirecting the user to the provider's login page
+ *
+ * Flow: Frontend -> /auth/{provider}/login -> Provider -> /auth/{provider}/callback
  */
-func (h *AuthHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
-	// Generate random state for CSRF protection
-	// This prevents attackers from initiating OAuth on behalf of users
+func (h *AuthHandler) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers.Get(r.PathValue("provider"))
+	if !ok {
+		http.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
 	state := generateRandomState()
-	
-	// Create Google authorization URL with our configuration
-	// AccessTypeOffline allows us to get refresh tokens (not used currently)
-	url := h.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-	
-	// Store state in secure cookie for validation in callback
-	// This ensures the callback request came from our redirect
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",                    // Cookie name
-		Value:    state,                            // Random state value
-		Expires:  time.Now().Add(10 * time.Minute), // Short expiration
-		HttpOnly: true,                             // Prevents XSS access
-		Secure:   false,                            // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,            // CSRF protection
-	})
-	
-	// Redirect user to Google for authentication
-	log.Printf("Redirecting user to Google OAuth: %s", url)
+	nonce := generateRandomState()
+
+	url, err := provider.AuthCodeURL(r.Context(), state, nonce)
+	if err != nil {
+		log.Printf("Failed to build %s authorization URL: %v", provider.Name(), err)
+		http.Error(w, "Login provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Store state and nonce in cookies for validation in the callback.
+	// The cookies are scoped to /auth/{provider}, so concurrent logins
+	// with different providers do not overwrite each other.
+	setOAuthCookie(w, provider.Name(), "oauth_state", state, oauthCookieTTL, h.auth.SecureCookies())
+	setOAuthCookie(w, provider.Name(), "oauth_nonce", nonce, oauthCookieTTL, h.auth.SecureCookies())
+
+	log.Printf("Redirecting user to %s login", provider.Name())
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
 /**
- * GoogleCallback - Handles OAuth callback from Google
- * 
- * This is where Google redirects users after they authorize our app.
+ * OAuthCallback - Handles the redirect back from {provider}
+ *
  * This method completes the OAuth flow by:
  * 1. Validating the state parameter (CSRF protection)
- * 2. Exchanging the authorization code for an access token
- * 3. Using the access token to get user info from Google
- * 4. Creating or finding the user in our database
- * 5. Generating a JWT token for our application
- * 6. Redirecting back to the frontend with the token
- * 
- * Security Validations:
- * - State parameter validation prevents CSRF attacks
- * - All errors are logged for debugging
- * - User input is validated before database operations
+ * 2. Exchanging the authorization code and verifying the identity
+ * 3. Finding the user the provider account is linked to, or creating one
+ * 4. Issuing a single-use sign-in code for our application
+ * 5. Redirecting back to the frontend with the code, which it exchanges
+ *    for tokens through ExchangeCode
+ *
+ * Link requests and verified email matches redirect to the frontend with
+ * "linked" or "link_token" parameters instead of a code.
  */
-func (h *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
-	log.Printf("OAuth callback received")
-	
+func (h *AuthHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers.Get(r.PathValue("provider"))
+	if !ok {
+		http.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+	log.Printf("OAuth callback received from %s", provider.Name())
+
 	// Step 1: Verify state parameter for CSRF protection
 	// The state must match what we set in the login endpoint
 	stateCookie, err := r.Cookie("oauth_state")
 	if err != nil || stateCookie.Value != r.URL.Query().Get("state") {
-		log.Printf("Invalid state parameter: cookie=%v, query=%v", 
-			stateCookie, r.URL.Query().Get("state"))
+		log.Printf("Invalid state parameter for %s callback", provider.Name())
 		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
 		return
 	}
-	
-	// Step 2: Clear the state cookie (single use)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    "",                               // Clear the value
-		Expires:  time.Now().Add(-1 * time.Hour),   // Set to past time
-		HttpOnly: true,
-	})
-	
-	// Step 3: Exchange authorization code for access token
-	// Google sends us a code that we exchange for an actual access token
+	nonceCookie, err := r.Cookie("oauth_nonce")
+	if err != nil {
+		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Clear the cookies (single use)
+	setOAuthCookie(w, provider.Name(), "oauth_state", "", -1, h.auth.SecureCookies())
+	setOAuthCookie(w, provider.Name(), "oauth_nonce", "", -1, h.auth.SecureCookies())
+	linkCookie, _ := r.Cookie("oauth_link")
+	if linkCookie != nil {
+		setOAuthCookie(w, provider.Name(), "oauth_link", "", -1, h.auth.SecureCookies())
+	}
+
+	if reason := r.URL.Query().Get("error"); reason != "" {
+		log.Printf("%s login was not completed: %s", provider.Name(), reason)
+		http.Error(w, "Login was cancelled or denied", http.StatusBadRequest)
+		return
+	}
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		log.Printf("No authorization code received")
 		http.Error(w, "No authorization code", http.StatusBadRequest)
 		return
 	}
-	
-	token, err := h.oauthConfig.Exchange(context.Background(), code)
+
+	// Step 2: Exchange the code and get the user's identity
+	identity, err := provider.Exchange(r.Context(), code, nonceCookie.Value)
 	if err != nil {
-		log.Printf("Token exchange error: %v", err)
-		http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
+		log.Printf("%s exchange failed: %v", provider.Name(), err)
+		http.Error(w, "Failed to get user info", http.StatusBadGateway)
 		return
 	}
-	
-	// Step 4: Get user information from Google using the access token
-	userInfo, err := h.getUserInfo(token.AccessToken)
-	if err != nil {
-		log.Printf("Failed to get user info: %v", err)
-		http.Error(w, "Failed to get user info", http.StatusInternalServerError)
+	if linkCookie != nil {
+		h.completeLinkRequest(w, r, identity, linkCookie.Value)
 		return
 	}
-	log.Printf("Retrieved user info for: %s (%s)", userInfo.Name, userInfo.Email)
-	
-	// Step 5: Find existing user or create new one
-	user, err := h.userService.GetUserByGoogleID(userInfo.ID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// User doesn't exist, create new account
-			log.Printf("Creating new user for Google ID: %s", userInfo.ID)
-			user, err = h.userService.CreateOAuthUser(userInfo.Email, userInfo.Name, userInfo.ID, userInfo.Picture)
-			if err != nil {
-				log.Printf("Failed to create user: %v", err)
-				http.Error(w, "Failed to create user", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			// Database error
+	if identity.Email == "" || !identity.EmailVerified {
+		http.Error(w, "Your account needs a verified email address", http.StatusBadRequest)
+		return
+	}
+	identity.Email = strings.ToLower(identity.Email) // Stored emails are lowercase
+	log.Printf("Retrieved %s identity %s (%s)", identity.Provider, identity.Subject, identity.Email)
+
+	// Step 3: Find existing user or create new one
+	user, err := h.userService.GetUserByProviderID(r.Context(), identity.Provider, identity.Subject)
+	if err == sql.ErrNoRows {
+		// An existing user with this verified email must confirm the link
+		existing, err := h.userService.GetUserByEmail(r.Context(), identity.Email)
+		if err == nil {
+			h.offerEmailLink(w, r, existing, identity)
+			return
+		} else if err != sql.ErrNoRows {
 			log.Printf("Database error: %v", err)
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
+
+		log.Printf("Creating new user for %s ID: %s", identity.Provider, identity.Subject)
+		user, err = h.createOAuthUser(r.Context(), identity)
+		if err == models.ErrEmailTaken {
+			http.Error(w, "An account with this email already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to create user: %v", err)
+			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			return
+		}
+	} else if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	} else {
-		// Existing user found
 		log.Printf("Found existing user: %s (ID: %d)", user.Username, user.ID)
 	}
-	
-	// Step 6: Generate JWT token for our application
-	jwtToken, err := h.generateJWT(user)
+
+	// Step 4: Users with 2FA finish signing in through POST /auth/mfa
+	ticket, err := h.startMFAChallenge(r.Context(), user, "")
+	if err != nil {
+		log.Printf("Failed to start MFA challenge: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if ticket != "" {
+		h.redirectToFrontend(w, r, url.Values{"mfa_ticket": {ticket}})
+		return
+	}
+
+	// Issue a single-use code; tokens are only handed out by ExchangeCode
+	authCode, err := h.authCodes.CreateAuthCode(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed to create sign-in code: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Step 5: Redirect back to frontend with the code
+	// The frontend AuthCallback component exchanges it for tokens
+	log.Printf("Redirecting to frontend with sign-in code")
+	h.redirectToFrontend(w, r, url.Values{"code": {authCode}})
+}
+
+/**
+ * ExchangeCode - Exchanges a sign-in code from the OAuth callback for tokens
+ *
+ * Returns 200 with an AuthResponse, or 400 if the code is unknown,
+ * expired or was already used.
+ */
+func (h *AuthHandler) ExchangeCode(w http.ResponseWriter, r *http.Request) {
+	var req ExchangeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	userID, err := h.authCodes.RedeemAuthCode(r.Context(), req.Code)
+	if err != nil {
+		if err == models.ErrInvalidAuthCode {
+			writeError(w, http.StatusBadRequest, "Sign-in expired, please start again")
+			return
+		}
+		log.Printf("Failed to redeem sign-in code: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to generate JWT: %v", err)
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		log.Printf("Failed to load user %d: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
-	
-	// Step 7: Redirect back to frontend with the JWT token
-	// The frontend AuthCallback component will handle the token
-	redirectURL := fmt.Sprintf("http://localhost:5173/auth/callback?token=%s", jwtToken)
-	log.Printf("Redirecting to frontend with token")
-	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+
+	log.Printf("User %s (ID: %d) signed in with a provider", user.Username, user.ID)
+	h.writeAuthResponse(w, r, http.StatusOK, user, req.DeviceName)
 }
 
 /**
- * getUserInfo - Fetches user information from Google
- * 
- * Uses the access token obtained from OAuth to make an authenticated
- * request to Google's userinfo API endpoint.
- * 
- * @param accessToken OAuth access token from Google
- * @return GoogleUserInfo struct with user details
- * @return error if request fails or JSON parsing fails
+ * completeLinkRequest - Links identity to the user who started the login
+ *
+ * Used instead of signing in when the login carried a link token. The
+ * frontend receives ?linked=<provider> on success.
  */
-func (h *AuthHandler) getUserInfo(accessToken string) (*GoogleUserInfo, error) {
-	// Make authenticated request to Google's userinfo endpoint
-	// This endpoint returns basic profile information for the authenticated user
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + accessToken)
+func (h *AuthHandler) completeLinkRequest(w http.ResponseWriter, r *http.Request, identity *oauth.Identity, token string) {
+	userID, err := h.identities.TakeLinkRequest(r.Context(), token, identity.Provider)
+	if err == models.ErrInvalidLinkToken {
+		http.Error(w, "Link request is invalid or has expired", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to request user info: %w", err)
+		log.Printf("Failed to load link request: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	}
-	defer resp.Body.Close()
-	
-	// Check for HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Google API returned status: %d", resp.StatusCode)
+
+	email := ""
+	if identity.EmailVerified {
+		email = identity.Email
 	}
-	
-	// Parse JSON response into our struct
-	var userInfo GoogleUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	_, err = h.identities.LinkIdentity(r.Context(), userID, identity.Provider, identity.Subject, email)
+	if err == models.ErrIdentityLinked {
+		http.Error(w, "This account is already linked to a user", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to link identity: %v", err)
+		http.Error(w, "Failed to link account", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %d linked %s identity %s", userID, identity.Provider, identity.Subject)
+	h.redirectToFrontend(w, r, url.Values{"linked": {identity.Provider}})
+}
+
+/**
+ * offerEmailLink - Hands an email-matched identity back for confirmation
+ *
+ * The frontend receives ?link_token=...&provider=... and, after the user
+ * signs in to their existing account, confirms the link through
+ * IdentityHandler.ConfirmLink.
+ */
+func (h *AuthHandler) offerEmailLink(w http.ResponseWriter, r *http.Request, user *models.User, identity *oauth.Identity) {
+	token, err := h.identities.CreatePendingLink(r.Context(), user.ID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		log.Printf("Failed to create pending link: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("%s identity %s matches user %d by email; awaiting confirmation", identity.Provider, identity.Subject, user.ID)
+	h.redirectToFrontend(w, r, url.Values{"link_token": {token}, "provider": {identity.Provider}})
+}
+
+/**
+ * createOAuthUser - Creates the user for a new provider account
+ *
+ * The username is derived from the provider's handle (falling back to the
+ * display name and then the email's local part, or "user"). If it is
+ * taken a random 4-digit discriminator is appended; the last attempt uses
+ * a random "user_<hex>" handle. The provider's display name is kept
+ * separately.
+ *
+ * @return The new user, or models.ErrEmailTaken if the email is in use
+ */
+func (h *AuthHandler) createOAuthUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	local, _, _ := strings.Cut(identity.Email, "@")
+	base := "user"
+	for _, candidate := range []string{identity.Username, identity.Name, local} {
+		if username := usernameFrom(candidate); username != "" {
+			base = username
+			break
+		}
+	}
+
+	displayName := strings.TrimSpace(identity.Name)
+	if displayName == "" {
+		displayName = identity.Username
+	}
+	displayName = truncate(displayName, maxDisplayNameLength)
+
+	username := base
+	for attempt := 1; ; attempt++ {
+		user, err := h.userService.CreateOAuthUser(ctx, identity.Provider, identity.Subject, identity.Email, username, displayName, identity.AvatarURL)
+		if err != models.ErrUsernameTaken || attempt > maxUsernameAttempts {
+			return user, err
+		}
+		if attempt < maxUsernameAttempts {
+			username = withDiscriminator(base)
+		} else {
+			suffix := make([]byte, 4)
+			rand.Read(suffix)
+			username = "user_" + hex.EncodeToString(suffix)
+		}
 	}
-	
-	return &userInfo, nil
 }
 
 /**
  * generateJWT - Creates a JWT token for authenticated users
- * 
+ *
  * Generates a signed JWT token containing user information that can be
- * used for subsequent API requests. The token is signed with our secret
- * and includes an expiration time.
- * 
+ * used for subsequent API requests. The token is signed by the shared
+ * tokens.Service and is short-lived; clients renew it with a refresh token.
+ *
  * Token Claims:
  * - user_id: Database user ID
  * - email: User's email address
  * - username: User's display name
- * - exp: Expiration time (24 hours from now)
+ * - jti: Session the token belongs to (checked for revocation)
+ * - exp: Expiration time (JWT_EXPIRATION from now)
  * - iat: Issued at time (current time)
- * 
+ *
  * @param user User model with database information
+ * @param sessionID Session the token is issued for
  * @return Signed JWT token string
  * @return error if token generation fails
  */
-func (h *AuthHandler) generateJWT(user *models.User) (string, error) {
-	// Create JWT claims with user information and metadata
-	claims := jwt.MapClaims{
-		"user_id":  user.ID,                               // Database user ID
-		"email":    user.Email,                            // User email
-		"username": user.Username,                         // Display name
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Expires in 24 hours
-		"iat":      time.Now().Unix(),                     // Issued now
+func (h *AuthHandler) generateJWT(user *models.User, sessionID string) (string, error) {
+	return h.tokens.Issue(user.ID, user.Email, user.Username, sessionID)
+}
+
+/**
+ * redirectToFrontend - Sends the browser to the frontend's /auth/callback page
+ *
+ * @param query Parameters for the AuthCallback component
+ */
+func (h *AuthHandler) redirectToFrontend(w http.ResponseWriter, r *http.Request, query url.Values) {
+	http.Redirect(w, r, h.frontendURL+"/auth/callback?"+query.Encode(), http.StatusTemporaryRedirect)
+}
+
+/**
+ * setOAuthCookie - Sets or clears a short-lived login flow cookie
+ *
+ * The cookie is scoped to /auth/{provider}, so it reaches both the login
+ * and callback endpoints wherever it is set from.
+ *
+ * @param provider Provider name the login flow is for
+ * @param ttl Lifetime; negative to delete the cookie
+ * @param secure Whether to mark the cookie Secure (config.Config.CookieSecure)
+ */
+func setOAuthCookie(w http.ResponseWriter, provider, name, value string, ttl time.Duration, secure bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/auth/" + provider,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,                 // Prevents XSS access
+		Secure:   secure,               // Sent over HTTPS only
+		SameSite: http.SameSiteLaxMode, // Sent on the provider's top-level redirect
 	}
-	
-	// Create and sign the token using HS256 algorithm
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(h.jwtSecret)
-	if err != nil {
-		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	if ttl < 0 {
+		cookie.MaxAge = -1
 	}
-	
-	return signedToken, nil
+	http.SetCookie(w, cookie)
 }
 
 /**
  * generateRandomState - Creates a cryptographically secure random state
- * 
- * Generates a random string used as the OAuth state parameter for CSRF protection.
- * The state is sent to Google and must be returned unchanged in the callback.
- * 
+ *
+ * Generates a random string used as the OAuth state parameter for CSRF
+ * protection and as the OIDC nonce.
+ *
  * @return Base64-encoded random string (32 bytes of entropy)
  */
 func generateRandomState() string {
 	// Generate 32 bytes of cryptographically secure random data
 	b := make([]byte, 32)
 	rand.Read(b) // crypto/rand for security (not math/rand)
-	
+
 	// Encode as URL-safe base64 for use in URLs and cookies
 	return base64.URLEncoding.EncodeToString(b)
-}
\ No newline at end of file
+}
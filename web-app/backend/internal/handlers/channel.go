@@ -0,0 +1,332 @@
+/**
+ * channel.go - Channel Management Handler
+ *
+ * REST endpoints for creating, renaming, deleting, listing and reordering
+ * channels within a server. Members only see channels they can view after
+ * the channel's permission overwrites are applied (see overwrite.go);
+ * mutations require Manage Channels. Changes are published to the gateway
+ * and only delivered to users who can view the channel.
+ *
+ * Endpoints:
+ * - GET    /api/servers/{id}/channels:           List channels ordered by position
+ * - POST   /api/servers/{id}/channels:           Create a channel
+ * - PUT    /api/servers/{id}/channels/positions: Atomically reorder channels
+ * - PATCH  /api/channels/{id}:                   Rename a channel
+ * - DELETE /api/channels/{id}:                   Delete a channel
+ */
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+// maxChannelNameLength matches channels.name VARCHAR(100)
+const maxChannelNameLength = 100
+
+/**
+ * ChannelHandler - Handler for channel management endpoints
+ */
+type ChannelHandler struct {
+	access           *channelAccess           // Channel permission checks
+	roleService      *models.RoleService      // Used for server permission checks
+	overwriteService *models.OverwriteService // Channel permission overwrites
+	channelService   *models.ChannelService   // Database service for channel operations
+	hub              *gateway.Hub             // Real-time event fan-out
+}
+
+/**
+ * ChannelRequest - Request body for creating or renaming a channel
+ */
+type ChannelRequest struct {
+	Name string `json:"name"` // Channel name
+	Type string `json:"type"` // text, voice or category (create only, defaults to text)
+}
+
+/**
+ * NewChannelHandler - Constructor for ChannelHandler
+ *
+ * @param db Database connection for channel operations
+ * @param hub Gateway hub for publishing channel events
+ * @return Configured ChannelHandler instance
+ */
+func NewChannelHandler(db *sql.DB, hub *gateway.Hub) *ChannelHandler {
+	return &ChannelHandler{
+		access:           newChannelAccess(db),
+		roleService:      models.NewRoleService(db),
+		overwriteService: models.NewOverwriteService(db),
+		channelService:   models.NewChannelService(db),
+		hub:              hub,
+	}
+}
+
+/**
+ * ListChannels - Lists the server's channels the user can view (members only)
+ */
+func (h *ChannelHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireMember(w, r, h.roleService, serverID, user.UserID); !ok {
+		return
+	}
+
+	channels, err := h.channelService.GetChannelsByServer(r.Context(), serverID)
+	if err == nil {
+		channels, err = h.visibleChannels(r.Context(), serverID, user.UserID, channels)
+	}
+	if err != nil {
+		log.Printf("Failed to list channels for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list channels")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channels)
+}
+
+/**
+ * CreateChannel - Creates a channel in a server (Manage Channels)
+ */
+func (h *ChannelHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req ChannelRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Type == "" {
+		req.Type = models.ChannelTypeText
+	}
+	if !models.IsValidChannelType(req.Type) {
+		writeError(w, http.StatusBadRequest, "Channel type must be one of: text, voice, category")
+		return
+	}
+	name, ok := validateChannelName(w, req.Name, req.Type)
+	if !ok {
+		return
+	}
+
+	if _, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageChannels); !ok {
+		return
+	}
+
+	channel, err := h.channelService.CreateChannel(r.Context(), serverID, name, req.Type)
+	if err != nil {
+		log.Printf("Failed to create channel in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create channel")
+		return
+	}
+
+	log.Printf("User %d created channel %d (%s) in server %d", user.UserID, channel.ID, channel.Name, serverID)
+	h.hub.PublishChannel(gateway.EventChannelCreate, serverID, channel.ID, channel)
+	writeJSON(w, http.StatusCreated, channel)
+}
+
+/**
+ * RenameChannel - Renames a channel (Manage Channels)
+ */
+func (h *ChannelHandler) RenameChannel(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req ChannelRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	channel, _, ok := h.access.requirePermission(w, r, channelID, user.UserID, permissions.ManageChannels)
+	if !ok {
+		return
+	}
+	name, ok := validateChannelName(w, req.Name, channel.Type)
+	if !ok {
+		return
+	}
+
+	channel, err := h.channelService.RenameChannel(r.Context(), channelID, name)
+	if err != nil {
+		h.handleLookupError(w, channelID, err)
+		return
+	}
+
+	h.hub.PublishChannel(gateway.EventChannelUpdate, channel.ServerID, channelID, channel)
+
+	writeJSON(w, http.StatusOK, channel)
+}
+
+/**
+ * DeleteChannel - Deletes a channel and its messages (Manage Channels)
+ */
+func (h *ChannelHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	channel, _, ok := h.access.requirePermission(w, r, channelID, user.UserID, permissions.ManageChannels)
+	if !ok {
+		return
+	}
+
+	// Resolve who can see the channel while its overwrites still exist
+	audience := h.hub.ChannelAudience(channel.ServerID, channelID)
+
+	if err := h.channelService.DeleteChannel(r.Context(), channelID); err != nil {
+		h.handleLookupError(w, channelID, err)
+		return
+	}
+
+	log.Printf("User %d deleted channel %d from server %d", user.UserID, channelID, channel.ServerID)
+	h.hub.PublishTo(gateway.EventChannelDelete, channel.ServerID, audience, channel)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * ReorderChannels - Updates positions of several channels at once
+ *
+ * Expects a JSON array of {id, position}. Either every update is applied or
+ * none are, so the client never sees a half-reordered list.
+ */
+func (h *ChannelHandler) ReorderChannels(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var positions []models.ChannelPosition
+	if !decodeJSON(w, r, &positions) {
+		return
+	}
+	if len(positions) == 0 {
+		writeError(w, http.StatusBadRequest, "At least one channel position is required")
+		return
+	}
+
+	if _, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageChannels); !ok {
+		return
+	}
+
+	channels, err := h.channelService.ReorderChannels(r.Context(), serverID, positions)
+	if err != nil {
+		if err == models.ErrChannelNotInServer {
+			writeError(w, http.StatusBadRequest, "All channels must belong to this server")
+			return
+		}
+		log.Printf("Failed to reorder channels in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to reorder channels")
+		return
+	}
+
+	for _, channel := range channels {
+		h.hub.PublishChannel(gateway.EventChannelUpdate, serverID, channel.ID, channel)
+	}
+
+	channels, err = h.visibleChannels(r.Context(), serverID, user.UserID, channels)
+	if err != nil {
+		log.Printf("Failed to filter channels for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channels)
+}
+
+/**
+ * visibleChannels - Filters channels down to those the user can view
+ *
+ * @param serverID Server the channels belong to
+ * @param userID User to check
+ * @param channels Channels of the server
+ * @return Channels the user has View Channels in, after overwrites
+ */
+func (h *ChannelHandler) visibleChannels(ctx context.Context, serverID, userID int, channels []*models.Channel) ([]*models.Channel, error) {
+	in, err := h.roleService.GetPermissionInputs(ctx, serverID, userID)
+	if err != nil {
+		return nil, err
+	}
+	overwrites, err := h.overwriteService.ListServerOverwrites(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []*models.Channel{}
+	for _, channel := range channels {
+		if permissions.ComputeChannel(in, overwrites[channel.ID]).Permissions.Has(permissions.ViewChannels) {
+			visible = append(visible, channel)
+		}
+	}
+	return visible, nil
+}
+
+/**
+ * handleLookupError - Maps channel lookup errors to HTTP responses
+ */
+func (h *ChannelHandler) handleLookupError(w http.ResponseWriter, channelID int, err error) {
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Channel not found")
+		return
+	}
+	log.Printf("Database error for channel %d: %v", channelID, err)
+	writeError(w, http.StatusInternalServerError, "Database error")
+}
+
+/**
+ * validateChannelName - Normalizes and validates a channel name
+ *
+ * Text channel names are lowercased with spaces replaced by dashes,
+ * matching how Discord displays them. Voice channels and categories
+ * keep their original casing.
+ *
+ * @return Normalized name and whether it is valid
+ */
+func validateChannelName(w http.ResponseWriter, name, channelType string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if channelType == models.ChannelTypeText {
+		name = strings.Join(strings.Fields(strings.ToLower(name)), "-")
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "Channel name cannot be empty")
+		return "", false
+	}
+	if len([]rune(name)) > maxChannelNameLength {
+		writeError(w, http.StatusBadRequest, "Channel name is too long")
+		return "", false
+	}
+	return name, true
+}
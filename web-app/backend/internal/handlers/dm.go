@@ -0,0 +1,277 @@
+/**
+ * dm.go - Direct Message Handler
+ *
+ * DMs are channels of type "dm" (exactly two participants) or "group_dm"
+ * (up to models.MaxGroupDMRecipients) that do not belong to any server.
+ * Messages are sent and read through the regular message endpoints, and
+ * their gateway events are delivered to the participants directly.
+ *
+ * Rules:
+ * - Opening a DM with one recipient returns the existing 1:1 DM if any
+ * - Any participant can add people to a group DM
+ * - Participants can remove themselves; only the owner can remove others
+ * - A group DM with no participants left is deleted
+ *
+ * Endpoints:
+ * - GET    /api/users/@me/channels:              List the current user's DMs
+ * - POST   /api/users/@me/channels:              Open a DM or create a group DM
+ * - PUT    /api/channels/{id}/recipients/{userID}: Add a participant to a group DM
+ * - DELETE /api/channels/{id}/recipients/{userID}: Remove a participant (or leave)
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+)
+
+/**
+ * DMHandler - Handler for direct message channel endpoints
+ */
+type DMHandler struct {
+	dmService *models.DMService // Database service for DM operations
+	access    *channelAccess    // Channel lookup and permission checks
+	hub       *gateway.Hub      // Real-time event fan-out
+}
+
+/**
+ * CreateDMRequest - Request body for opening a DM
+ *
+ * One recipient opens (or reuses) a 1:1 DM; more create a group DM.
+ */
+type CreateDMRequest struct {
+	RecipientIDs []int `json:"recipient_ids"` // Other participants
+}
+
+/**
+ * RecipientEvent - Gateway data for CHANNEL_RECIPIENT_ADD and CHANNEL_RECIPIENT_REMOVE
+ */
+type RecipientEvent struct {
+	ChannelID int `json:"channel_id"` // Group DM that changed
+	UserID    int `json:"user_id"`    // Participant who was added or removed
+}
+
+/**
+ * NewDMHandler - Constructor for DMHandler
+ *
+ * @param db Database connection for DM operations
+ * @param hub Gateway hub for publishing DM events
+ * @return Configured DMHandler instance
+ */
+func NewDMHandler(db *sql.DB, hub *gateway.Hub) *DMHandler {
+	return &DMHandler{
+		dmService: models.NewDMService(db),
+		access:    newChannelAccess(db),
+		hub:       hub,
+	}
+}
+
+/**
+ * ListDMs - Lists the current user's DMs, most recently active first
+ */
+func (h *DMHandler) ListDMs(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	dms, err := h.dmService.ListDMs(r.Context(), user.UserID)
+	if err != nil {
+		log.Printf("Failed to list DMs for user %d: %v", user.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list DMs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dms)
+}
+
+/**
+ * CreateDM - Opens a 1:1 DM or creates a group DM
+ *
+ * Returns 200 with the existing channel when a 1:1 DM already exists and
+ * 201 when a new channel was created.
+ */
+func (h *DMHandler) CreateDM(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var req CreateDMRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	recipients := make([]int, 0, len(req.RecipientIDs))
+	seen := map[int]bool{user.UserID: true}
+	for _, id := range req.RecipientIDs {
+		if id <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid recipient ID")
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		writeError(w, http.StatusBadRequest, "At least one other recipient is required")
+		return
+	}
+	if len(recipients)+1 > models.MaxGroupDMRecipients {
+		writeError(w, http.StatusBadRequest, "Group DMs are limited to 10 participants")
+		return
+	}
+
+	var dm *models.DMChannel
+	var err error
+	created := true
+	if len(recipients) == 1 {
+		dm, created, err = h.dmService.GetOrCreateDM(r.Context(), user.UserID, recipients[0])
+	} else {
+		dm, err = h.dmService.CreateGroupDM(r.Context(), user.UserID, recipients)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		log.Printf("Failed to create DM for user %d: %v", user.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create DM")
+		return
+	}
+
+	if !created {
+		writeJSON(w, http.StatusOK, dm)
+		return
+	}
+
+	log.Printf("User %d opened %s %d", user.UserID, dm.Type, dm.ID)
+	h.access.publish(h.hub, &dm.Channel, gateway.EventChannelCreate, dm)
+	writeJSON(w, http.StatusCreated, dm)
+}
+
+/**
+ * AddRecipient - Adds a participant to a group DM (participants only)
+ */
+func (h *DMHandler) AddRecipient(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "userID")
+	if !ok {
+		return
+	}
+
+	channel, ok := h.requireGroupDM(w, r, channelID, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := h.dmService.AddRecipient(r.Context(), channelID, targetID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			writeError(w, http.StatusNotFound, "User not found")
+		case models.ErrAlreadyRecipient:
+			writeError(w, http.StatusConflict, "User is already in this conversation")
+		case models.ErrDMFull:
+			writeError(w, http.StatusBadRequest, "Group DMs are limited to 10 participants")
+		default:
+			log.Printf("Failed to add user %d to channel %d: %v", targetID, channelID, err)
+			writeError(w, http.StatusInternalServerError, "Failed to add recipient")
+		}
+		return
+	}
+
+	dm, err := h.dmService.GetDM(r.Context(), channelID)
+	if err != nil {
+		log.Printf("Failed to load channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("User %d added user %d to group DM %d", user.UserID, targetID, channelID)
+	h.hub.PublishUsers(gateway.EventChannelCreate, []int{targetID}, dm)
+	h.access.publish(h.hub, channel, gateway.EventRecipientAdd, RecipientEvent{ChannelID: channelID, UserID: targetID})
+	writeJSON(w, http.StatusOK, dm)
+}
+
+/**
+ * RemoveRecipient - Removes a participant from a group DM
+ *
+ * Removing yourself leaves the conversation; removing anyone else
+ * requires being the group DM's owner.
+ */
+func (h *DMHandler) RemoveRecipient(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "userID")
+	if !ok {
+		return
+	}
+
+	channel, ok := h.requireGroupDM(w, r, channelID, user.UserID)
+	if !ok {
+		return
+	}
+	if targetID != user.UserID {
+		dm, err := h.dmService.GetDM(r.Context(), channelID)
+		if err != nil {
+			log.Printf("Failed to load channel %d: %v", channelID, err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if dm.OwnerID == nil || *dm.OwnerID != user.UserID {
+			writeError(w, http.StatusForbidden, "Only the group owner can remove other participants")
+			return
+		}
+	}
+
+	if err := h.dmService.RemoveRecipient(r.Context(), channelID, targetID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "User is not in this conversation")
+			return
+		}
+		log.Printf("Failed to remove user %d from channel %d: %v", targetID, channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to remove recipient")
+		return
+	}
+
+	log.Printf("User %d removed user %d from group DM %d", user.UserID, targetID, channelID)
+	h.hub.PublishUsers(gateway.EventChannelDelete, []int{targetID}, channel)
+	h.access.publish(h.hub, channel, gateway.EventRecipientRemove, RecipientEvent{ChannelID: channelID, UserID: targetID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * requireGroupDM - Checks the channel is a group DM the user participates in
+ *
+ * @return The channel and whether the check passed
+ */
+func (h *DMHandler) requireGroupDM(w http.ResponseWriter, r *http.Request, channelID, userID int) (*models.Channel, bool) {
+	channel, _, ok := h.access.requireMember(w, r, channelID, userID)
+	if !ok {
+		return nil, false
+	}
+	if channel.Type != models.ChannelTypeGroupDM {
+		writeError(w, http.StatusBadRequest, "Participants can only be changed in group DMs")
+		return nil, false
+	}
+	return channel, true
+}
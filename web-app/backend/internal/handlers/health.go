@@ -0,0 +1,174 @@
+/**
+ * health.go - Liveness and Readiness Probes
+ *
+ * Liveness only says the process is serving HTTP; orchestrators restart
+ * the server when it fails, so it never depends on anything external.
+ * Readiness says the server can handle traffic right now; orchestrators
+ * stop routing to it while it fails, without restarting it.
+ *
+ * Readiness runs every check concurrently, each bounded by checkTimeout:
+ * - database:   the connection pool can reach PostgreSQL
+ * - migrations: the schema has no migrations pending for this binary
+ * - anything registered with AddCheck (e.g. the gateway event hub)
+ *
+ * Endpoints:
+ * - GET /livez:      200 {"status": "ok"}
+ * - GET /readyz:     200 if every check passes, 503 otherwise, with a
+ *                    per-component report; why a check failed is only
+ *                    logged, never sent to the (unauthenticated) caller
+ * - GET /api/health: Same as /readyz, kept for existing clients
+ */
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/user/web-app/internal/migrate"
+)
+
+// checkTimeout bounds each readiness check
+const checkTimeout = 2 * time.Second
+
+/**
+ * HealthCheck - Reports whether one dependency is usable
+ *
+ * @param ctx Cancelled when the check takes longer than checkTimeout
+ * @return nil if healthy, otherwise why not
+ */
+type HealthCheck func(ctx context.Context) error
+
+/**
+ * ComponentStatus - Result of one readiness check
+ */
+type ComponentStatus struct {
+	Status     string `json:"status"`          // "ok" or "fail"
+	Error      string `json:"error,omitempty"` // Generic failure summary; details are only logged
+	DurationMS int64  `json:"duration_ms"`     // How long the check took
+}
+
+/**
+ * HealthResponse - Body of /livez and /readyz
+ */
+type HealthResponse struct {
+	Status     string                     `json:"status"`               // "ok" or "unavailable"
+	Components map[string]ComponentStatus `json:"components,omitempty"` // Readiness only
+}
+
+/**
+ * HealthHandler - Handler for liveness and readiness probes
+ */
+type HealthHandler struct {
+	names  []string // Check names, in registration order
+	checks map[string]HealthCheck
+}
+
+/**
+ * NewHealthHandler - Constructor for HealthHandler
+ *
+ * Registers the database and migrations checks.
+ *
+ * @param db Database connection to ping
+ * @param migrator Migrations embedded in this binary
+ * @return Configured HealthHandler instance
+ */
+func NewHealthHandler(db *sql.DB, migrator *migrate.Migrator) *HealthHandler {
+	h := &HealthHandler{checks: make(map[string]HealthCheck)}
+	h.AddCheck("database", db.PingContext)
+	h.AddCheck("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migrations pending", pending)
+		}
+		return nil
+	})
+	return h
+}
+
+/**
+ * AddCheck - Registers another readiness check
+ *
+ * Call at startup before serving requests. Adding a name twice replaces
+ * the earlier check.
+ *
+ * @param name Component name used in the report
+ * @param check Function reporting the component's health
+ */
+func (h *HealthHandler) AddCheck(name string, check HealthCheck) {
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+/**
+ * Live - Liveness probe; always 200 while the server is serving
+ */
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+/**
+ * Ready - Readiness probe; 503 if any check fails
+ */
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	components := make(map[string]ComponentStatus, len(h.names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range h.names {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			status, err := runCheck(r.Context(), check)
+			if err != nil {
+				log.Printf("Readiness check %s failed: %v", name, err)
+			}
+			mu.Lock()
+			components[name] = status
+			mu.Unlock()
+		}(name, h.checks[name])
+	}
+	wg.Wait()
+
+	response := HealthResponse{Status: "ok", Components: components}
+	code := http.StatusOK
+	for _, status := range components {
+		if status.Status != "ok" {
+			response.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, response)
+}
+
+// runCheck runs one check with checkTimeout and times it. The probes are
+// public, so the report only says whether the check failed or timed out;
+// the returned error carries the details for the log.
+func runCheck(ctx context.Context, check HealthCheck) (ComponentStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := ComponentStatus{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = "fail"
+		status.Error = "check failed"
+		if ctx.Err() == context.DeadlineExceeded {
+			status.Error = "check timed out"
+		}
+	}
+	return status, err
+}
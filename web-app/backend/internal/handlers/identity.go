@@ -0,0 +1,218 @@
+/**
+ * identity.go - Linked Login Provider Identities Handler
+ *
+ * A user can sign in with a password (if set) and with every provider
+ * account linked to them. Accounts are linked in two ways:
+ *
+ * Adding an identity while signed in:
+ * 1. POST /api/users/@me/identities/{provider}/link stores a single-use
+ *    link token in an HttpOnly oauth_link cookie and returns a login URL
+ * 2. The frontend navigates to it; the provider callback links the
+ *    account to the user who requested it instead of signing in
+ * The token never appears in a URL: a link URL that could be sent to
+ * someone else would let an attacker attach the victim's provider
+ * account to the attacker's user, and so sign the victim in to it later.
+ *
+ * Verified email match:
+ * - Signing in with an unknown provider account whose verified email
+ *   belongs to an existing user does not create a new user. The callback
+ *   redirects with a link_token instead; once the user signs in to the
+ *   existing account they confirm it with POST /api/users/@me/identities/confirm.
+ *   Requiring the existing account's credentials keeps a provider that
+ *   vouches for someone else's email from taking the account over.
+ *
+ * Endpoints:
+ * - GET    /api/users/@me/identities:                  List linked identities
+ * - POST   /api/users/@me/identities/{provider}/link:  Start linking a provider account
+ * - POST   /api/users/@me/identities/confirm:          Confirm an email-matched link
+ * - DELETE /api/users/@me/identities/{identityID}:     Unlink (never the last login method)
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/user/web-app/internal/middleware"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/oauth"
+)
+
+/**
+ * IdentityHandler - Handler for linked identity endpoints
+ */
+type IdentityHandler struct {
+	identityService *models.IdentityService // Database service for identity operations
+	userService     *models.UserService     // Database service for user operations
+	providers       *oauth.Registry         // Login providers by name
+	secureCookies   bool                    // Mark the oauth_link cookie Secure
+}
+
+/**
+ * IdentitiesResponse - Response body for GET /api/users/@me/identities
+ */
+type IdentitiesResponse struct {
+	HasPassword bool               `json:"has_password"` // Whether email/password login works
+	Identities  []*models.Identity `json:"identities"`   // Linked provider accounts
+}
+
+/**
+ * LinkResponse - Response body for starting a link
+ */
+type LinkResponse struct {
+	URL string `json:"url"` // Backend path to navigate the same browser to
+}
+
+/**
+ * ConfirmLinkRequest - Request body for POST /api/users/@me/identities/confirm
+ */
+type ConfirmLinkRequest struct {
+	LinkToken string `json:"link_token"` // From the provider callback redirect
+}
+
+/**
+ * NewIdentityHandler - Constructor for IdentityHandler
+ *
+ * @param db Database connection for identity operations
+ * @param auth Supplies the secure-cookie setting shared with AuthHandler
+ * @param providers Login providers (see config.OAuthConfig)
+ * @return Configured IdentityHandler instance
+ */
+func NewIdentityHandler(db *sql.DB, auth *middleware.Authenticator, providers *oauth.Registry) *IdentityHandler {
+	return &IdentityHandler{
+		identityService: models.NewIdentityService(db),
+		userService:     models.NewUserService(db),
+		providers:       providers,
+		secureCookies:   auth.SecureCookies(),
+	}
+}
+
+/**
+ * ListIdentities - Lists the current user's login methods
+ */
+func (h *IdentityHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+
+	user, err := h.userService.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list identities")
+		return
+	}
+	identities, err := h.identityService.ListIdentities(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to list identities for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list identities")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, IdentitiesResponse{
+		HasPassword: user.PasswordHash != nil,
+		Identities:  identities,
+	})
+}
+
+/**
+ * StartLink - Starts linking a {provider} account to the current user
+ *
+ * Sets the oauth_link cookie and returns the /auth/{provider}/login URL
+ * to open in the same browser, relative to the API base URL. The request
+ * must be made with credentials so the browser stores the cookie; the
+ * link works for models.PendingLinkTTL.
+ */
+func (h *IdentityHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	provider, ok := h.providers.Get(r.PathValue("provider"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	token, err := h.identityService.CreateLinkRequest(r.Context(), claims.UserID, provider.Name())
+	if err != nil {
+		log.Printf("Failed to create link request for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to start linking")
+		return
+	}
+
+	setOAuthCookie(w, provider.Name(), "oauth_link", token, models.PendingLinkTTL, h.secureCookies)
+	writeJSON(w, http.StatusOK, LinkResponse{URL: "/auth/" + provider.Name() + "/login"})
+}
+
+/**
+ * ConfirmLink - Links a provider account whose email matched this user
+ *
+ * The link token is only accepted from the user it was issued for.
+ * Returns 201 with the new identity.
+ */
+func (h *IdentityHandler) ConfirmLink(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req ConfirmLinkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.LinkToken == "" {
+		writeError(w, http.StatusBadRequest, "link_token is required")
+		return
+	}
+
+	identity, err := h.identityService.ConfirmPendingLink(r.Context(), claims.UserID, req.LinkToken)
+	if err != nil {
+		switch err {
+		case models.ErrInvalidLinkToken:
+			writeError(w, http.StatusBadRequest, "Invalid or expired link token")
+		case models.ErrIdentityLinked:
+			writeError(w, http.StatusConflict, "This account is already linked to a user")
+		default:
+			log.Printf("Failed to confirm link for user %d: %v", claims.UserID, err)
+			writeError(w, http.StatusInternalServerError, "Failed to link account")
+		}
+		return
+	}
+
+	log.Printf("User %d linked %s identity %s", claims.UserID, identity.Provider, identity.Subject)
+	writeJSON(w, http.StatusCreated, identity)
+}
+
+/**
+ * UnlinkIdentity - Removes one of the current user's identities
+ *
+ * Returns 409 if it is the only way left to sign in.
+ */
+func (h *IdentityHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	identityID, ok := pathID(w, r, "identityID")
+	if !ok {
+		return
+	}
+
+	if err := h.identityService.UnlinkIdentity(r.Context(), claims.UserID, identityID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			writeError(w, http.StatusNotFound, "Identity not found")
+		case models.ErrLastLoginMethod:
+			writeError(w, http.StatusConflict, "Cannot remove your only login method")
+		default:
+			log.Printf("Failed to unlink identity %d for user %d: %v", identityID, claims.UserID, err)
+			writeError(w, http.StatusInternalServerError, "Failed to unlink identity")
+		}
+		return
+	}
+
+	log.Printf("User %d unlinked identity %d", claims.UserID, identityID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,232 @@
+/**
+ * invite.go - Server Invite Handler
+ *
+ * Invites are short random codes that let another user join a server.
+ * Each invite can optionally expire after a number of seconds and/or
+ * stop working after a number of uses.
+ *
+ * Access:
+ * - Members with Create Invites can create an invite
+ * - Members with Manage Server can list and revoke a server's invites
+ * - Anyone (no auth) can preview an invite
+ * - Any authenticated user can accept a valid invite
+ *
+ * Endpoints:
+ * - POST   /api/servers/{id}/invites:        Create an invite
+ * - GET    /api/servers/{id}/invites:        List invites (Manage Server)
+ * - DELETE /api/servers/{id}/invites/{code}: Revoke an invite (Manage Server)
+ * - GET    /api/invites/{code}:              Public preview (server name, icon, member count)
+ * - POST   /api/invites/{code}/accept:       Join the server
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+// maxInviteAge caps how far in the future an invite may expire
+const maxInviteAge = 7 * 24 * time.Hour
+
+/**
+ * InviteHandler - Handler for invite endpoints
+ */
+type InviteHandler struct {
+	inviteService *models.InviteService // Database service for invite operations
+	roleService   *models.RoleService   // Used for permission checks
+	hub           *gateway.Hub          // Real-time event fan-out for joins
+}
+
+/**
+ * CreateInviteRequest - Request body for creating an invite
+ *
+ * Omitted or zero values mean unlimited uses / never expires.
+ */
+type CreateInviteRequest struct {
+	MaxUses       int `json:"max_uses"`        // Maximum number of joins
+	MaxAgeSeconds int `json:"max_age_seconds"` // Lifetime in seconds
+}
+
+/**
+ * NewInviteHandler - Constructor for InviteHandler
+ *
+ * @param db Database connection for invite operations
+ * @param hub Gateway hub for publishing member events
+ * @return Configured InviteHandler instance
+ */
+func NewInviteHandler(db *sql.DB, hub *gateway.Hub) *InviteHandler {
+	return &InviteHandler{
+		inviteService: models.NewInviteService(db),
+		roleService:   models.NewRoleService(db),
+		hub:           hub,
+	}
+}
+
+/**
+ * CreateInvite - Creates an invite for a server (Create Invites)
+ */
+func (h *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req CreateInviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.MaxUses < 0 || req.MaxAgeSeconds < 0 {
+		writeError(w, http.StatusBadRequest, "max_uses and max_age_seconds cannot be negative")
+		return
+	}
+	if time.Duration(req.MaxAgeSeconds)*time.Second > maxInviteAge {
+		writeError(w, http.StatusBadRequest, "Invites cannot last longer than 7 days")
+		return
+	}
+
+	if _, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.CreateInvites); !ok {
+		return
+	}
+
+	var maxUses *int
+	if req.MaxUses > 0 {
+		maxUses = &req.MaxUses
+	}
+	var expiresAt *time.Time
+	if req.MaxAgeSeconds > 0 {
+		t := time.Now().Add(time.Duration(req.MaxAgeSeconds) * time.Second)
+		expiresAt = &t
+	}
+
+	invite, err := h.inviteService.CreateInvite(r.Context(), serverID, user.UserID, maxUses, expiresAt)
+	if err != nil {
+		log.Printf("Failed to create invite for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create invite")
+		return
+	}
+
+	log.Printf("User %d created invite %s for server %d", user.UserID, invite.Code, serverID)
+	writeJSON(w, http.StatusCreated, invite)
+}
+
+/**
+ * ListInvites - Lists a server's invites (Manage Server)
+ */
+func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageServer); !ok {
+		return
+	}
+
+	invites, err := h.inviteService.ListInvites(r.Context(), serverID)
+	if err != nil {
+		log.Printf("Failed to list invites for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list invites")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, invites)
+}
+
+/**
+ * RevokeInvite - Deletes an invite so it can no longer be used (Manage Server)
+ */
+func (h *InviteHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	code := r.PathValue("code")
+
+	if _, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageServer); !ok {
+		return
+	}
+
+	if err := h.inviteService.RevokeInvite(r.Context(), serverID, code); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Invite not found")
+			return
+		}
+		log.Printf("Failed to revoke invite %s: %v", code, err)
+		writeError(w, http.StatusInternalServerError, "Failed to revoke invite")
+		return
+	}
+
+	log.Printf("User %d revoked invite %s for server %d", user.UserID, code, serverID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * PreviewInvite - Shows what server an invite leads to (no auth required)
+ */
+func (h *InviteHandler) PreviewInvite(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+
+	preview, err := h.inviteService.GetInvitePreview(r.Context(), code)
+	if err != nil {
+		h.handleInviteError(w, code, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+/**
+ * AcceptInvite - Joins the invite's server as a regular member
+ */
+func (h *InviteHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	code := r.PathValue("code")
+
+	member, err := h.inviteService.AcceptInvite(r.Context(), code, user.UserID)
+	if err != nil {
+		if err == models.ErrAlreadyMember {
+			writeError(w, http.StatusConflict, "You are already a member of this server")
+			return
+		}
+		h.handleInviteError(w, code, err)
+		return
+	}
+
+	log.Printf("User %d joined server %d with invite %s", user.UserID, member.ServerID, code)
+	announceJoin(h.hub, member)
+	writeJSON(w, http.StatusOK, member)
+}
+
+/**
+ * handleInviteError - Maps invite lookup errors to HTTP responses
+ */
+func (h *InviteHandler) handleInviteError(w http.ResponseWriter, code string, err error) {
+	if err == models.ErrInviteInvalid {
+		writeError(w, http.StatusNotFound, "Invite is invalid or has expired")
+		return
+	}
+	log.Printf("Database error for invite %s: %v", code, err)
+	writeError(w, http.StatusInternalServerError, "Database error")
+}
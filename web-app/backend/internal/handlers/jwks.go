@@ -0,0 +1,28 @@
+/**
+ * jwks.go - JSON Web Key Set Endpoint
+ *
+ * Publishes the public keys access tokens can be verified with, so other
+ * services can check our tokens without sharing JWT_SECRET. Tokens carry a
+ * "kid" header naming the key that signed them. The set is empty when
+ * tokens are signed with HS256 only.
+ *
+ * Endpoints:
+ * - GET /.well-known/jwks.json: Current verification keys
+ */
+
+package handlers
+
+import (
+	"net/http"
+)
+
+/**
+ * JWKS - Serves the token service's public verification keys
+ *
+ * Responses may be cached briefly; clients should refetch when they see
+ * an unknown kid.
+ */
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.tokens.JWKS())
+}
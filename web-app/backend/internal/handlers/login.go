@@ -0,0 +1,181 @@
+/**
+ * login.go - Email/Password Authentication Handler
+ *
+ * Local accounts sign in with an email address and password instead of
+ * Google. Both endpoints respond with the same access and refresh tokens
+ * the OAuth flow issues, so the rest of the API does not care how a user
+ * signed in.
+ *
+ * Rules:
+ * - Emails are compared case-insensitively (stored lowercased)
+ * - Passwords must be 8-72 bytes (bcrypt ignores anything past 72)
+ * - Usernames and emails are unique; conflicts return 409
+ * - Login failures never reveal whether the email exists, and accounts
+ *   created through Google (no password) cannot log in this way
+ *
+ * Endpoints:
+ * - POST /auth/register: Create a local account and return a token
+ * - POST /auth/login:    Exchange email and password for a token
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/user/web-app/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt's input limit in bytes
+)
+
+// dummyPasswordHash is compared against when no account matches a login so
+// that unknown emails take as long as wrong passwords.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+/**
+ * RegisterRequest - Request body for creating a local account
+ */
+type RegisterRequest struct {
+	Username    string `json:"username"`     // Unique handle (see user.go for the rules)
+	DisplayName string `json:"display_name"` // Optional display name
+	Email       string `json:"email"`        // Unique email address, used to log in
+	Password    string `json:"password"`     // Plain-text password, hashed before storage
+	DeviceName  string `json:"device_name"`  // Optional label for the new session
+}
+
+/**
+ * LoginRequest - Request body for email/password login
+ */
+type LoginRequest struct {
+	Email      string `json:"email"`       // Email the account was registered with
+	Password   string `json:"password"`    // Account password
+	DeviceName string `json:"device_name"` // Optional label for the new session
+}
+
+/**
+ * Register - Creates a local account and signs the user in
+ *
+ * Returns 201 with an AuthResponse, 400 on invalid input and 409 when the
+ * username or email is already in use.
+ */
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	username, ok := checkUsername(req.Username)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Username must be 2-32 characters of a-z, 0-9, _ and .")
+		return
+	}
+	displayName, ok := normalizeDisplayName(req.DisplayName)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Display name must be at most 32 characters")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "A valid email address is required")
+		return
+	}
+	if len(req.Password) < minPasswordLength || len(req.Password) > maxPasswordLength {
+		writeError(w, http.StatusBadRequest, "Password must be between 8 and 72 characters")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	user, err := h.userService.CreateLocalUser(r.Context(), username, displayName, email, string(hash))
+	if err != nil {
+		switch err {
+		case models.ErrUsernameTaken:
+			writeError(w, http.StatusConflict, "Username is already taken")
+		case models.ErrEmailTaken:
+			writeError(w, http.StatusConflict, "Email is already registered")
+		default:
+			log.Printf("Failed to create local user: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to create account")
+		}
+		return
+	}
+
+	log.Printf("Registered local user: %s (ID: %d)", user.Username, user.ID)
+	h.writeAuthResponse(w, r, http.StatusCreated, user, req.DeviceName)
+}
+
+/**
+ * Login - Signs a local user in with email and password
+ *
+ * Returns 200 with an AuthResponse or 401 for any bad credentials. Users
+ * with 2FA get 200 with an MFAChallengeResponse instead and finish with
+ * POST /auth/mfa.
+ */
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	email, _ := normalizeEmail(req.Email)
+	user, err := h.userService.GetUserByEmail(r.Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to look up user for login: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	hash := dummyPasswordHash
+	if user != nil && user.PasswordHash != nil {
+		hash = []byte(*user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || user == nil || user.PasswordHash == nil {
+		writeError(w, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	ticket, err := h.startMFAChallenge(r.Context(), user, req.DeviceName)
+	if err != nil {
+		log.Printf("Failed to start MFA challenge for user %d: %v", user.ID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if ticket != "" {
+		writeJSON(w, http.StatusOK, MFAChallengeResponse{
+			MFARequired: true,
+			Ticket:      ticket,
+			ExpiresIn:   int(models.MFAChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	log.Printf("User logged in with password: %s (ID: %d)", user.Username, user.ID)
+	h.writeAuthResponse(w, r, http.StatusOK, user, req.DeviceName)
+}
+
+/**
+ * normalizeEmail - Validates a bare email address and lowercases it
+ *
+ * @return The normalized address and whether it was valid
+ */
+func normalizeEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return "", false
+	}
+	return email, true
+}
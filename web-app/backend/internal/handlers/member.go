@@ -0,0 +1,232 @@
+/**
+ * member.go - Server Membership Handler
+ *
+ * REST endpoints for listing, leaving and kicking server members. Joining
+ * a server goes through invite acceptance (see invite.go), which calls
+ * announceJoin so every new membership row is followed by a gateway
+ * subscription and a SERVER_MEMBER_ADD event.
+ *
+ * Kick rules:
+ * - The caller must have Kick Members
+ * - The target's highest role must rank strictly below the caller's
+ *   (owners can never be kicked)
+ *
+ * Endpoints:
+ * - GET    /api/servers/{id}/members:          Page through members (?after=<user id>&limit=)
+ * - DELETE /api/servers/{id}/members/@me:      Leave a server
+ * - DELETE /api/servers/{id}/members/{userID}: Kick a member
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+const (
+	defaultMemberLimit = 100  // Page size when ?limit is omitted
+	maxMemberLimit     = 1000 // Largest page a client may request
+)
+
+/**
+ * MemberHandler - Handler for server membership endpoints
+ */
+type MemberHandler struct {
+	memberService *models.MemberService // Database service for membership operations
+	roleService   *models.RoleService   // Used for permission and hierarchy checks
+	hub           *gateway.Hub          // Real-time event fan-out
+}
+
+/**
+ * MemberRemoveEvent - Gateway data for SERVER_MEMBER_REMOVE
+ */
+type MemberRemoveEvent struct {
+	ServerID int `json:"server_id"` // Server the user left or was kicked from
+	UserID   int `json:"user_id"`   // User who is no longer a member
+}
+
+/**
+ * NewMemberHandler - Constructor for MemberHandler
+ *
+ * @param db Database connection for membership operations
+ * @param hub Gateway hub for publishing member events
+ * @return Configured MemberHandler instance
+ */
+func NewMemberHandler(db *sql.DB, hub *gateway.Hub) *MemberHandler {
+	return &MemberHandler{
+		memberService: models.NewMemberService(db),
+		roleService:   models.NewRoleService(db),
+		hub:           hub,
+	}
+}
+
+/**
+ * ListMembers - Returns a page of members ordered by user ID (members only)
+ */
+func (h *MemberHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	after, limit := 0, defaultMemberLimit
+	if raw := r.URL.Query().Get("after"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid after parameter")
+			return
+		}
+		after = n
+	}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+		limit = min(n, maxMemberLimit)
+	}
+
+	if _, ok := requireMember(w, r, h.roleService, serverID, user.UserID); !ok {
+		return
+	}
+
+	members, err := h.memberService.ListMembers(r.Context(), serverID, after, limit)
+	if err != nil {
+		log.Printf("Failed to list members of server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list members")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, members)
+}
+
+/**
+ * LeaveServer - Removes the current user from a server
+ *
+ * Owners cannot leave; they must delete the server instead.
+ */
+func (h *MemberHandler) LeaveServer(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	perms, ok := requireMember(w, r, h.roleService, serverID, user.UserID)
+	if !ok {
+		return
+	}
+	if perms.IsOwner() {
+		writeError(w, http.StatusBadRequest, "The owner cannot leave the server; delete it instead")
+		return
+	}
+
+	if !h.removeMember(w, r, serverID, user.UserID) {
+		return
+	}
+
+	log.Printf("User %d left server %d", user.UserID, serverID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * KickMember - Removes another member from a server
+ */
+func (h *MemberHandler) KickMember(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "userID")
+	if !ok {
+		return
+	}
+	if targetID == user.UserID {
+		writeError(w, http.StatusBadRequest, "Use the leave endpoint to remove yourself")
+		return
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.KickMembers)
+	if !ok {
+		return
+	}
+
+	target, isMember, err := loadPermissions(r.Context(), h.roleService, serverID, targetID)
+	if err != nil {
+		log.Printf("Failed to load permissions in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !isMember {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	if !perms.CanManage(target.HighestPosition) {
+		writeError(w, http.StatusForbidden, "You can only kick members with a lower role than yours")
+		return
+	}
+
+	if !h.removeMember(w, r, serverID, targetID) {
+		return
+	}
+
+	log.Printf("User %d kicked user %d from server %d", user.UserID, targetID, serverID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * removeMember - Deletes the membership row and notifies the gateway
+ *
+ * The removal event is published before unsubscribing so the removed
+ * user's own clients learn they are no longer a member.
+ *
+ * @return true if the member was removed
+ */
+func (h *MemberHandler) removeMember(w http.ResponseWriter, r *http.Request, serverID, userID int) bool {
+	if err := h.memberService.RemoveMember(r.Context(), serverID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Member not found")
+			return false
+		}
+		log.Printf("Failed to remove user %d from server %d: %v", userID, serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to remove member")
+		return false
+	}
+
+	h.hub.Publish(gateway.EventMemberRemove, serverID, MemberRemoveEvent{ServerID: serverID, UserID: userID})
+	h.hub.Unsubscribe(userID, serverID)
+	return true
+}
+
+/**
+ * announceJoin - Notifies the gateway that a user joined a server
+ *
+ * Shared by every join path so the new member's connections start
+ * receiving the server's events and existing members see the join.
+ *
+ * @param hub Gateway hub
+ * @param member Newly inserted membership
+ */
+func announceJoin(hub *gateway.Hub, member *models.Member) {
+	hub.Subscribe(member.UserID, member.ServerID)
+	hub.Publish(gateway.EventMemberAdd, member.ServerID, member)
+}
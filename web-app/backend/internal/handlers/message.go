@@ -0,0 +1,353 @@
+/**
+ * message.go - Message Handler
+ *
+ * REST endpoints for sending, listing, editing and deleting messages in a
+ * channel. Reading requires View Channels and sending requires Send
+ * Messages, both after the channel's overwrites are applied; a message
+ * can only be edited or deleted by its author or by a member with Manage
+ * Messages. DM participants can read and send but only modify their own
+ * messages. Every change is published to the gateway.
+ *
+ * Pagination uses message IDs as keyset cursors instead of OFFSET, so
+ * pages stay stable while new messages arrive:
+ * - GET ...?limit=50             newest 50 messages
+ * - GET ...?before=<id>&limit=50 50 messages older than <id>
+ * - GET ...?after=<id>&limit=50  50 messages newer than <id>
+ *
+ * Endpoints:
+ * - POST   /api/channels/{id}/messages:             Send a message
+ * - GET    /api/channels/{id}/messages:             List messages
+ * - PATCH  /api/channels/{id}/messages/{messageID}: Edit a message
+ * - DELETE /api/channels/{id}/messages/{messageID}: Delete a message
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+const (
+	maxMessageLength    = 2000 // Maximum message content length in characters
+	defaultMessageLimit = 50   // Page size when ?limit is omitted
+	maxMessageLimit     = 100  // Largest page a client may request
+)
+
+/**
+ * MessageHandler - Handler for message endpoints
+ */
+type MessageHandler struct {
+	access         *channelAccess         // Channel lookup and permission checks
+	messageService *models.MessageService // Database service for message operations
+	hub            *gateway.Hub           // Real-time event fan-out
+}
+
+/**
+ * MessageRequest - Request body for sending or editing a message
+ */
+type MessageRequest struct {
+	Content string `json:"content"` // Message text
+}
+
+/**
+ * MessagePage - Response body for message listing
+ */
+type MessagePage struct {
+	Messages []*models.Message `json:"messages"` // Messages in chronological order
+	HasMore  bool              `json:"has_more"` // More messages exist in the requested direction
+}
+
+/**
+ * MessageDeleteEvent - Gateway data for MESSAGE_DELETE
+ */
+type MessageDeleteEvent struct {
+	ID        int `json:"id"`         // Deleted message ID
+	ChannelID int `json:"channel_id"` // Channel the message was in
+	ServerID  int `json:"server_id"`  // Server the channel belongs to
+}
+
+/**
+ * NewMessageHandler - Constructor for MessageHandler
+ *
+ * @param db Database connection for message operations
+ * @param hub Gateway hub for publishing message events
+ * @return Configured MessageHandler instance
+ */
+func NewMessageHandler(db *sql.DB, hub *gateway.Hub) *MessageHandler {
+	return &MessageHandler{
+		access:         newChannelAccess(db),
+		messageService: models.NewMessageService(db),
+		hub:            hub,
+	}
+}
+
+/**
+ * SendMessage - Posts a message to a text channel
+ */
+func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req MessageRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	content, ok := validateMessageContent(w, req.Content)
+	if !ok {
+		return
+	}
+
+	channel, perms, ok := h.access.requireMember(w, r, channelID, user.UserID)
+	if !ok {
+		return
+	}
+	if !perms.Permissions.Has(permissions.SendMessages) {
+		writeError(w, http.StatusForbidden, "You do not have permission to send messages in this channel")
+		return
+	}
+	if !channel.IsTextBased() {
+		writeError(w, http.StatusBadRequest, "Messages can only be sent to text channels")
+		return
+	}
+
+	message, err := h.messageService.CreateMessage(r.Context(), channelID, user.UserID, content)
+	if err != nil {
+		log.Printf("Failed to create message in channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+
+	h.access.publish(h.hub, channel, gateway.EventMessageCreate, message)
+
+	writeJSON(w, http.StatusCreated, message)
+}
+
+/**
+ * ListMessages - Returns a page of messages using keyset pagination
+ */
+func (h *MessageHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	query, ok := parseMessageQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := h.access.requireMember(w, r, channelID, user.UserID); !ok {
+		return
+	}
+
+	messages, hasMore, err := h.messageService.ListMessages(r.Context(), channelID, query)
+	if err != nil {
+		log.Printf("Failed to list messages in channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list messages")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessagePage{Messages: messages, HasMore: hasMore})
+}
+
+/**
+ * EditMessage - Replaces a message's content and marks it as edited
+ */
+func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	messageID, ok := pathID(w, r, "messageID")
+	if !ok {
+		return
+	}
+
+	var req MessageRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	content, ok := validateMessageContent(w, req.Content)
+	if !ok {
+		return
+	}
+
+	channel, _, ok := h.requireModifiable(w, r, channelID, messageID, user.UserID)
+	if !ok {
+		return
+	}
+
+	message, err := h.messageService.UpdateMessage(r.Context(), messageID, content)
+	if err != nil {
+		h.handleLookupError(w, messageID, err)
+		return
+	}
+
+	h.access.publish(h.hub, channel, gateway.EventMessageUpdate, message)
+
+	writeJSON(w, http.StatusOK, message)
+}
+
+/**
+ * DeleteMessage - Deletes a message
+ */
+func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	messageID, ok := pathID(w, r, "messageID")
+	if !ok {
+		return
+	}
+
+	channel, message, ok := h.requireModifiable(w, r, channelID, messageID, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := h.messageService.DeleteMessage(r.Context(), messageID); err != nil {
+		h.handleLookupError(w, messageID, err)
+		return
+	}
+
+	h.access.publish(h.hub, channel, gateway.EventMessageDelete, MessageDeleteEvent{
+		ID:        messageID,
+		ChannelID: channelID,
+		ServerID:  channel.ServerID,
+	})
+
+	if message.UserID != user.UserID {
+		log.Printf("User %d deleted message %d by user %d", user.UserID, messageID, message.UserID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * requireModifiable - Checks the user may edit or delete a message
+ *
+ * The message must belong to the channel in the URL, and the user must be
+ * its author or have Manage Messages in the channel's server.
+ *
+ * @return The channel, the message and whether the check passed
+ */
+func (h *MessageHandler) requireModifiable(w http.ResponseWriter, r *http.Request, channelID, messageID, userID int) (*models.Channel, *models.Message, bool) {
+	channel, perms, ok := h.access.requireMember(w, r, channelID, userID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	message, err := h.messageService.GetMessageByID(r.Context(), messageID)
+	if err != nil {
+		h.handleLookupError(w, messageID, err)
+		return nil, nil, false
+	}
+	if message.ChannelID != channelID {
+		writeError(w, http.StatusNotFound, "Message not found")
+		return nil, nil, false
+	}
+
+	if message.UserID != userID && !perms.Permissions.Has(permissions.ManageMessages) {
+		if perms.MFARestricted {
+			writeForbidden(w, perms, permissions.ManageMessages)
+		} else {
+			writeError(w, http.StatusForbidden, "You can only modify your own messages")
+		}
+		return nil, nil, false
+	}
+
+	return channel, message, true
+}
+
+/**
+ * handleLookupError - Maps message lookup errors to HTTP responses
+ */
+func (h *MessageHandler) handleLookupError(w http.ResponseWriter, messageID int, err error) {
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	log.Printf("Database error for message %d: %v", messageID, err)
+	writeError(w, http.StatusInternalServerError, "Database error")
+}
+
+/**
+ * validateMessageContent - Trims and validates message content
+ *
+ * @return Trimmed content and whether it is valid
+ */
+func validateMessageContent(w http.ResponseWriter, content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		writeError(w, http.StatusBadRequest, "Message content cannot be empty")
+		return "", false
+	}
+	if len([]rune(content)) > maxMessageLength {
+		writeError(w, http.StatusBadRequest, "Message content is too long")
+		return "", false
+	}
+	return content, true
+}
+
+/**
+ * parseMessageQuery - Parses before/after/limit query parameters
+ *
+ * @return Parsed query and whether the parameters were valid
+ */
+func parseMessageQuery(w http.ResponseWriter, r *http.Request) (models.MessageQuery, bool) {
+	query := models.MessageQuery{Limit: defaultMessageLimit}
+	values := r.URL.Query()
+
+	parse := func(name string, dst *int) bool {
+		raw := values.Get(name)
+		if raw == "" {
+			return true
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid "+name+" parameter")
+			return false
+		}
+		*dst = n
+		return true
+	}
+
+	if !parse("before", &query.Before) || !parse("after", &query.After) || !parse("limit", &query.Limit) {
+		return query, false
+	}
+	if query.Before > 0 && query.After > 0 {
+		writeError(w, http.StatusBadRequest, "Use either before or after, not both")
+		return query, false
+	}
+	if query.Limit > maxMessageLimit {
+		query.Limit = maxMessageLimit
+	}
+
+	return query, true
+}
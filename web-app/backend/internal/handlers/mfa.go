@@ -0,0 +1,425 @@
+/**
+ * mfa.go - Two-Factor Authentication Handler
+ *
+ * Users can protect their account with TOTP codes from an authenticator
+ * app, plus single-use recovery codes for when the app is lost.
+ *
+ * Enrollment:
+ * 1. POST /api/users/@me/mfa/totp returns a new secret and otpauth:// URI
+ *    (shown as a QR code by the frontend)
+ * 2. POST /api/users/@me/mfa/totp/confirm with a code from the app turns
+ *    2FA on and returns RecoveryCodeCount recovery codes, shown only once
+ *
+ * Sign-in challenge:
+ * - Once 2FA is on, password and provider sign-ins stop before any token
+ *   is issued and return an MFA ticket instead (see AuthHandler.Login and
+ *   OAuthCallback)
+ * - POST /auth/mfa with the ticket and a TOTP code or recovery code
+ *   finishes the sign-in; each ticket allows models.MaxMFAAttempts tries
+ *
+ * Every TOTP code is accepted once; recovery codes are stored as SHA-256
+ * hashes and marked used. Turning 2FA off or regenerating recovery codes
+ * needs a current code too, with models.MaxMFAAttempts tries per user
+ * every models.MFAAttemptWindow.
+ *
+ * Endpoints:
+ * - GET    /api/users/@me/mfa:                Status and remaining recovery codes
+ * - POST   /api/users/@me/mfa/totp:           Start enrollment
+ * - POST   /api/users/@me/mfa/totp/confirm:   Confirm enrollment with a code
+ * - DELETE /api/users/@me/mfa/totp:           Turn 2FA off (code required)
+ * - POST   /api/users/@me/mfa/recovery-codes: Replace recovery codes (code required)
+ * - POST   /auth/mfa:                         Complete a sign-in challenge
+ */
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/totp"
+)
+
+// totpIssuer names this service in authenticator apps.
+const totpIssuer = "Discord Clone"
+
+/**
+ * MFAHandler - Handler for 2FA enrollment and management endpoints
+ */
+type MFAHandler struct {
+	mfaService  *models.MFAService  // Database service for 2FA state
+	userService *models.UserService // Used for the otpauth account label
+}
+
+/**
+ * SecondFactor - A TOTP code or a recovery code; one must be set
+ */
+type SecondFactor struct {
+	Code         string `json:"code"`          // 6-digit code from the authenticator app
+	RecoveryCode string `json:"recovery_code"` // One of the user's recovery codes
+}
+
+/**
+ * MFAStatusResponse - Response body for GET /api/users/@me/mfa
+ */
+type MFAStatusResponse struct {
+	TOTPEnabled            bool `json:"totp_enabled"`             // Whether 2FA is on
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"` // Unused recovery codes
+}
+
+/**
+ * TOTPEnrollmentResponse - Response body for starting enrollment
+ */
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`      // Base32 secret for manual entry
+	OTPAuthURI string `json:"otpauth_uri"` // URI to render as a QR code
+}
+
+/**
+ * RecoveryCodesResponse - Response body listing new recovery codes
+ */
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // Shown once; only hashes are stored
+}
+
+/**
+ * MFAChallengeResponse - Returned instead of tokens when a sign-in needs 2FA
+ */
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"` // Always true
+	Ticket      string `json:"ticket"`       // Pass to POST /auth/mfa
+	ExpiresIn   int    `json:"expires_in"`   // Ticket lifetime in seconds
+}
+
+/**
+ * MFAVerifyRequest - Request body for POST /auth/mfa
+ */
+type MFAVerifyRequest struct {
+	Ticket string `json:"ticket"` // From the MFAChallengeResponse
+	SecondFactor
+}
+
+/**
+ * NewMFAHandler - Constructor for MFAHandler
+ *
+ * @param db Database connection for 2FA operations
+ * @return Configured MFAHandler instance
+ */
+func NewMFAHandler(db *sql.DB) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  models.NewMFAService(db),
+		userService: models.NewUserService(db),
+	}
+}
+
+/**
+ * GetStatus - Reports whether 2FA is on for the current user
+ */
+func (h *MFAHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+
+	state, err := h.mfaService.GetTOTPState(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to load 2FA state for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	remaining, err := h.mfaService.CountRecoveryCodes(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to count recovery codes for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MFAStatusResponse{
+		TOTPEnabled:            state.Enabled,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+/**
+ * StartTOTP - Generates a secret for the current user to enroll
+ *
+ * Starting again before confirming replaces the secret. Returns 409 if
+ * 2FA is already on.
+ */
+func (h *MFAHandler) StartTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Failed to generate TOTP secret: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+	if err := h.mfaService.BeginTOTPEnrollment(r.Context(), claims.UserID, secret); err != nil {
+		if err == models.ErrTOTPAlreadyEnabled {
+			writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		log.Printf("Failed to start TOTP enrollment for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(secret, totpIssuer, claims.Email),
+	})
+}
+
+/**
+ * ConfirmTOTP - Turns 2FA on once the user proves their app works
+ *
+ * Returns 201 with the recovery codes.
+ */
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req SecondFactor
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	state, err := h.mfaService.GetTOTPState(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to load 2FA state for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if state.Enabled {
+		writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if state.Secret == "" {
+		writeError(w, http.StatusBadRequest, "Start enrollment first")
+		return
+	}
+
+	step, ok := totp.Validate(state.Secret, req.Code, time.Now())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := h.mfaService.EnableTOTP(r.Context(), claims.UserID, step)
+	if err != nil {
+		if err == models.ErrTOTPAlreadyEnabled {
+			writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		log.Printf("Failed to enable 2FA for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	log.Printf("User %d enabled two-factor authentication", claims.UserID)
+	writeJSON(w, http.StatusCreated, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+/**
+ * DisableTOTP - Turns 2FA off for the current user
+ *
+ * Requires a TOTP or recovery code so a stolen session alone cannot
+ * remove the second factor.
+ */
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req SecondFactor
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !checkUserSecondFactor(w, r, h.mfaService, claims.UserID, req) {
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(r.Context(), claims.UserID); err != nil {
+		log.Printf("Failed to disable 2FA for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	log.Printf("User %d disabled two-factor authentication", claims.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * RegenerateRecoveryCodes - Replaces the current user's recovery codes
+ *
+ * Old codes stop working. Requires a TOTP or recovery code.
+ */
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req SecondFactor
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !checkUserSecondFactor(w, r, h.mfaService, claims.UserID, req) {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(r.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to regenerate recovery codes for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to regenerate recovery codes")
+		return
+	}
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+/**
+ * VerifyMFA - Completes a sign-in that is waiting for its second factor
+ *
+ * Returns 200 with an AuthResponse, 401 for a wrong code and 400 once the
+ * ticket is unknown, expired or out of attempts.
+ */
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFAVerifyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Ticket == "" {
+		writeError(w, http.StatusBadRequest, "ticket is required")
+		return
+	}
+
+	challenge, err := h.mfa.AttemptChallenge(r.Context(), req.Ticket)
+	if err != nil {
+		if err == models.ErrInvalidMFATicket {
+			writeError(w, http.StatusBadRequest, "Sign-in expired, please start again")
+			return
+		}
+		log.Printf("Failed to load MFA challenge: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !checkSecondFactor(w, r, h.mfa, challenge.UserID, req.SecondFactor) {
+		return
+	}
+	if err := h.mfa.CompleteChallenge(r.Context(), req.Ticket); err != nil {
+		if err == models.ErrInvalidMFATicket {
+			writeError(w, http.StatusBadRequest, "Sign-in expired, please start again")
+			return
+		}
+		log.Printf("Failed to complete MFA challenge: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", challenge.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("User %s (ID: %d) completed two-factor sign-in", user.Username, user.ID)
+	h.writeAuthResponse(w, r, http.StatusOK, user, challenge.DeviceName)
+}
+
+/**
+ * startMFAChallenge - Starts a 2FA challenge if user needs one
+ *
+ * Called by every sign-in path after the first factor succeeded.
+ *
+ * @param deviceName Session label to use once the challenge is completed
+ * @return The ticket ("" if user does not have 2FA) or a database error
+ */
+func (h *AuthHandler) startMFAChallenge(ctx context.Context, user *models.User, deviceName string) (string, error) {
+	if !user.MFAEnabled {
+		return "", nil
+	}
+	return h.mfa.CreateChallenge(ctx, user.ID, truncate(deviceName, 100))
+}
+
+/**
+ * checkUserSecondFactor - checkSecondFactor for an already signed-in user
+ *
+ * Sign-in challenges limit attempts per ticket; this limits them per user
+ * (models.MaxMFAAttempts per models.MFAAttemptWindow) so a stolen access
+ * token cannot be used to guess codes. Writes 429 once the limit is
+ * reached. An accepted code clears the count.
+ *
+ * @return true if the second factor was accepted
+ */
+func checkUserSecondFactor(w http.ResponseWriter, r *http.Request, mfa *models.MFAService, userID int, factor SecondFactor) bool {
+	if err := mfa.AttemptSecondFactor(r.Context(), userID); err != nil {
+		if err == models.ErrMFAAttemptsExceeded {
+			writeError(w, http.StatusTooManyRequests, "Too many attempts, please try again later")
+			return false
+		}
+		log.Printf("Failed to count second factor attempt for user %d: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !checkSecondFactor(w, r, mfa, userID, factor) {
+		return false
+	}
+	if err := mfa.ResetSecondFactorAttempts(r.Context(), userID); err != nil {
+		log.Printf("Failed to reset second factor attempts for user %d: %v", userID, err)
+	}
+	return true
+}
+
+/**
+ * checkSecondFactor - Verifies a TOTP code or recovery code for userID
+ *
+ * Writes 400 if neither is given or 2FA is off, and 401 if the code is
+ * wrong or was already used.
+ *
+ * @return true if the second factor was accepted
+ */
+func checkSecondFactor(w http.ResponseWriter, r *http.Request, mfa *models.MFAService, userID int, factor SecondFactor) bool {
+	var ok bool
+	var err error
+
+	switch {
+	case factor.Code != "":
+		var state *models.TOTPState
+		state, err = mfa.GetTOTPState(r.Context(), userID)
+		if err == nil && !state.Enabled {
+			writeError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+			return false
+		}
+		if err == nil {
+			var step int64
+			if step, ok = totp.Validate(state.Secret, factor.Code, time.Now()); ok {
+				ok, err = mfa.UseTOTPStep(r.Context(), userID, step)
+			}
+		}
+	case factor.RecoveryCode != "":
+		ok, err = mfa.UseRecoveryCode(r.Context(), userID, factor.RecoveryCode)
+	default:
+		writeError(w, http.StatusBadRequest, "code or recovery_code is required")
+		return false
+	}
+
+	if err != nil {
+		log.Printf("Failed to verify second factor for user %d: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Invalid code")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,285 @@
+/**
+ * overwrite.go - Channel Permission Overwrite Handler
+ *
+ * Overwrites adjust server-level permissions for a single channel, e.g.
+ * denying View Channels to @everyone and allowing it to a "Staff" role to
+ * make a private #staff channel. Each overwrite targets one role or one
+ * member and carries allow and deny bitfields limited to channel-scoped
+ * permissions (see permissions.ComputeChannel for how they combine).
+ *
+ * Access:
+ * - The caller must be able to view the channel and have Manage Roles
+ * - Role overwrites can only target roles below the caller's highest role
+ * - Callers can only allow or deny permissions they have in the channel
+ *
+ * Changing an overwrite can hide or reveal the channel. Users who lose
+ * access get CHANNEL_DELETE, users who gain it get CHANNEL_CREATE and
+ * everyone else who can still see it gets CHANNEL_UPDATE.
+ *
+ * Endpoints:
+ * - GET    /api/channels/{id}/permissions:                   List overwrites
+ * - PUT    /api/channels/{id}/permissions/{type}/{targetID}: Create or replace an overwrite
+ * - DELETE /api/channels/{id}/permissions/{type}/{targetID}: Remove an overwrite
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+/**
+ * OverwriteHandler - Handler for channel permission overwrite endpoints
+ */
+type OverwriteHandler struct {
+	overwriteService *models.OverwriteService // Database service for overwrite operations
+	access           *channelAccess           // Channel lookup and permission checks
+	roleService      *models.RoleService      // Used to validate role targets
+	memberService    *models.MemberService    // Used to validate member targets
+	hub              *gateway.Hub             // Real-time event fan-out
+}
+
+/**
+ * OverwriteRequest - Request body for creating or replacing an overwrite
+ */
+type OverwriteRequest struct {
+	Allow permissions.Permission `json:"allow"` // Bits granted in the channel
+	Deny  permissions.Permission `json:"deny"`  // Bits removed in the channel
+}
+
+/**
+ * NewOverwriteHandler - Constructor for OverwriteHandler
+ *
+ * @param db Database connection for overwrite operations
+ * @param hub Gateway hub for publishing channel events
+ * @return Configured OverwriteHandler instance
+ */
+func NewOverwriteHandler(db *sql.DB, hub *gateway.Hub) *OverwriteHandler {
+	return &OverwriteHandler{
+		overwriteService: models.NewOverwriteService(db),
+		access:           newChannelAccess(db),
+		roleService:      models.NewRoleService(db),
+		memberService:    models.NewMemberService(db),
+		hub:              hub,
+	}
+}
+
+/**
+ * ListOverwrites - Lists a channel's overwrites (Manage Roles)
+ */
+func (h *OverwriteHandler) ListOverwrites(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, _, ok := h.access.requirePermission(w, r, channelID, user.UserID, permissions.ManageRoles); !ok {
+		return
+	}
+
+	overwrites, err := h.overwriteService.ListOverwrites(r.Context(), channelID)
+	if err != nil {
+		log.Printf("Failed to list overwrites for channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list overwrites")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overwrites)
+}
+
+/**
+ * SetOverwrite - Creates or replaces a role or member overwrite (Manage Roles)
+ */
+func (h *OverwriteHandler) SetOverwrite(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	overwriteType, ok := parseOverwriteType(w, r)
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "targetID")
+	if !ok {
+		return
+	}
+
+	var req OverwriteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if (req.Allow|req.Deny)&^permissions.ChannelScoped != 0 {
+		writeError(w, http.StatusBadRequest, "Overwrites can only use channel permissions")
+		return
+	}
+	if req.Allow&req.Deny != 0 {
+		writeError(w, http.StatusBadRequest, "A permission cannot be both allowed and denied")
+		return
+	}
+
+	channel, perms, ok := h.access.requirePermission(w, r, channelID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	if !perms.Permissions.Has(req.Allow | req.Deny) {
+		writeError(w, http.StatusForbidden, "You can only allow or deny permissions you have in this channel")
+		return
+	}
+	if !h.requireTarget(w, r, perms, channel.ServerID, overwriteType, targetID) {
+		return
+	}
+
+	before := h.hub.ChannelAudience(channel.ServerID, channelID)
+
+	overwrite, err := h.overwriteService.SetOverwrite(r.Context(), channelID, overwriteType, targetID, req.Allow, req.Deny)
+	if err != nil {
+		log.Printf("Failed to set overwrite on channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to save overwrite")
+		return
+	}
+
+	log.Printf("User %d set %s overwrite %d on channel %d", user.UserID, overwriteType, targetID, channelID)
+	h.publishVisibility(channel, before)
+	writeJSON(w, http.StatusOK, overwrite)
+}
+
+/**
+ * DeleteOverwrite - Removes a role or member overwrite (Manage Roles)
+ */
+func (h *OverwriteHandler) DeleteOverwrite(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	channelID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	overwriteType, ok := parseOverwriteType(w, r)
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "targetID")
+	if !ok {
+		return
+	}
+
+	channel, perms, ok := h.access.requirePermission(w, r, channelID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	if !h.requireTarget(w, r, perms, channel.ServerID, overwriteType, targetID) {
+		return
+	}
+
+	before := h.hub.ChannelAudience(channel.ServerID, channelID)
+
+	if err := h.overwriteService.DeleteOverwrite(r.Context(), channelID, overwriteType, targetID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Overwrite not found")
+			return
+		}
+		log.Printf("Failed to delete overwrite on channel %d: %v", channelID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to delete overwrite")
+		return
+	}
+
+	log.Printf("User %d removed %s overwrite %d from channel %d", user.UserID, overwriteType, targetID, channelID)
+	h.publishVisibility(channel, before)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * requireTarget - Checks that an overwrite target exists and may be edited
+ *
+ * Role targets must belong to the server and rank below the caller;
+ * member targets must be members of the server.
+ *
+ * @return true if the check passed
+ */
+func (h *OverwriteHandler) requireTarget(w http.ResponseWriter, r *http.Request, perms permissions.Result, serverID int, overwriteType permissions.OverwriteType, targetID int) bool {
+	if overwriteType == permissions.OverwriteRole {
+		role, err := h.roleService.GetRole(r.Context(), serverID, targetID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "Role not found")
+				return false
+			}
+			log.Printf("Database error for role %d: %v", targetID, err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return false
+		}
+		if !perms.CanManage(role.Position) {
+			writeError(w, http.StatusForbidden, "You can only manage roles below your highest role")
+			return false
+		}
+		return true
+	}
+
+	if _, err := h.memberService.GetMember(r.Context(), serverID, targetID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Member not found")
+			return false
+		}
+		log.Printf("Failed to load member %d in server %d: %v", targetID, serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	return true
+}
+
+/**
+ * publishVisibility - Tells connected users how an overwrite change affects them
+ *
+ * @param channel Channel whose overwrites changed
+ * @param before Audience resolved before the change
+ */
+func (h *OverwriteHandler) publishVisibility(channel *models.Channel, before map[int]bool) {
+	after := h.hub.ChannelAudience(channel.ServerID, channel.ID)
+
+	lost, gained, kept := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	for userID := range before {
+		if after[userID] {
+			kept[userID] = true
+		} else {
+			lost[userID] = true
+		}
+	}
+	for userID := range after {
+		if !before[userID] {
+			gained[userID] = true
+		}
+	}
+
+	h.hub.PublishTo(gateway.EventChannelDelete, channel.ServerID, lost, channel)
+	h.hub.PublishTo(gateway.EventChannelCreate, channel.ServerID, gained, channel)
+	h.hub.PublishTo(gateway.EventChannelUpdate, channel.ServerID, kept, channel)
+}
+
+/**
+ * parseOverwriteType - Parses the {type} path parameter
+ *
+ * @return "role" or "member" and whether parsing succeeded
+ */
+func parseOverwriteType(w http.ResponseWriter, r *http.Request) (permissions.OverwriteType, bool) {
+	overwriteType := permissions.OverwriteType(r.PathValue("type"))
+	if overwriteType != permissions.OverwriteRole && overwriteType != permissions.OverwriteMember {
+		writeError(w, http.StatusBadRequest, "Overwrite type must be role or member")
+		return "", false
+	}
+	return overwriteType, true
+}
@@ -0,0 +1,222 @@
+/**
+ * refresh.go - Refresh Token Handler
+ *
+ * Access tokens (JWTs) live for JWT_EXPIRATION. Every sign-in starts a
+ * session (see session.go) and also returns an opaque refresh token that
+ * can be exchanged for a new pair until it expires (models.RefreshTokenTTL).
+ *
+ * Rotation:
+ * - Each refresh token works once; refreshing returns a replacement
+ * - Tokens descended from the same sign-in form a family tied to its session
+ * - Presenting an already rotated token means it was copied, so the
+ *   session is revoked and both holders must sign in again
+ *
+ * Cookie mode:
+ * - Sign-in requests with "X-Auth-Mode: cookie" get their tokens as
+ *   HttpOnly cookies and a csrf_token in the body instead (see
+ *   middleware/cookies.go)
+ * - Refresh and logout then take the refresh token from its cookie when
+ *   the body has none; the X-CSRF-Token header is required in that case
+ *   and refreshed tokens are set as cookies again
+ *
+ * Endpoints:
+ * - POST /auth/refresh: Exchange a refresh token for new tokens
+ * - POST /auth/logout:  Revoke the refresh token's session
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/user/web-app/internal/middleware"
+	"github.com/user/web-app/internal/models"
+)
+
+/**
+ * RefreshRequest - Request body for POST /auth/refresh and POST /auth/logout
+ */
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"` // Token from the last AuthResponse; omitted in cookie mode
+}
+
+/**
+ * Refresh - Rotates a refresh token and issues a new access token
+ *
+ * Returns 200 with an AuthResponse or 401 if the token is unknown,
+ * expired, revoked or reused.
+ */
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	presented, fromCookie, ok := refreshTokenFrom(w, r, req)
+	if !ok {
+		return
+	}
+
+	userID, sessionID, refreshToken, err := h.refreshTokens.RotateRefreshToken(r.Context(), presented)
+	if err != nil {
+		switch err {
+		case models.ErrRefreshTokenReused:
+			log.Printf("Refresh token reuse detected; revoked session %s", sessionID)
+			endSession(h.auth, h.hub, sessionID)
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		case models.ErrInvalidRefreshToken:
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		default:
+			log.Printf("Failed to rotate refresh token: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to refresh token")
+		}
+		return
+	}
+
+	user, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		log.Printf("Failed to load user %d: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	token, err := h.generateJWT(user, sessionID)
+	if err != nil {
+		log.Printf("Failed to generate JWT for user %d: %v", user.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.writeTokens(w, http.StatusOK, &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.tokens.TTL().Seconds()),
+		User:         user,
+	}, fromCookie || middleware.WantsCookies(r))
+}
+
+/**
+ * Logout - Revokes the refresh token's session
+ *
+ * Access tokens issued for the session stop working as well, and its
+ * gateway connections are closed. Unknown
+ * tokens are ignored so logging out twice is harmless. Auth cookies are
+ * always cleared.
+ */
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	presented, _, ok := refreshTokenFrom(w, r, req)
+	if !ok {
+		return
+	}
+
+	sessionID, err := h.refreshTokens.RevokeRefreshToken(r.Context(), presented)
+	if err != nil && err != models.ErrInvalidRefreshToken {
+		log.Printf("Failed to revoke refresh token: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	endSession(h.auth, h.hub, sessionID)
+	h.auth.ClearAuthCookies(w)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * issueTokens - Starts a session for user and issues its first tokens
+ *
+ * The session records the request's IP address and User-Agent.
+ *
+ * @param deviceName Optional client-supplied label for the session
+ * @return AuthResponse ready to send to the client
+ */
+func (h *AuthHandler) issueTokens(r *http.Request, user *models.User, deviceName string) (*AuthResponse, error) {
+	session, refreshToken, err := h.sessions.CreateSession(r.Context(), user.ID, truncate(strings.TrimSpace(deviceName), 100), clientIP(r), r.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := h.generateJWT(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.tokens.TTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+/**
+ * writeAuthResponse - Signs user in and writes the resulting AuthResponse
+ *
+ * Requests with "X-Auth-Mode: cookie" get their tokens as cookies.
+ *
+ * @param status HTTP status for a successful response
+ * @param deviceName Optional client-supplied label for the session
+ */
+func (h *AuthHandler) writeAuthResponse(w http.ResponseWriter, r *http.Request, status int, user *models.User, deviceName string) {
+	tokens, err := h.issueTokens(r, user, deviceName)
+	if err != nil {
+		log.Printf("Failed to issue tokens for user %d: %v", user.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.writeTokens(w, status, tokens, middleware.WantsCookies(r))
+}
+
+/**
+ * writeTokens - Writes an AuthResponse, moving the tokens into cookies
+ *
+ * @param cookies true to set the tokens as HttpOnly cookies and return a
+ *        CSRF token in their place
+ */
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, status int, resp *AuthResponse, cookies bool) {
+	if cookies {
+		csrfToken, err := h.auth.SetAuthCookies(w, resp.Token, h.tokens.TTL(), resp.RefreshToken, models.RefreshTokenTTL)
+		if err != nil {
+			log.Printf("Failed to set auth cookies: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		resp.Token, resp.RefreshToken, resp.CSRFToken = "", "", csrfToken
+	}
+	writeJSON(w, status, resp)
+}
+
+/**
+ * refreshTokenFrom - Gets the refresh token from the body or its cookie
+ *
+ * The cookie is only used when the body has no token, and then needs a
+ * valid X-CSRF-Token header. Writes 400 or 403 on failure.
+ *
+ * @return The token, whether it came from the cookie, and false if an
+ *         error response was written
+ */
+func refreshTokenFrom(w http.ResponseWriter, r *http.Request, req RefreshRequest) (string, bool, bool) {
+	if req.RefreshToken != "" {
+		return req.RefreshToken, false, true
+	}
+	cookie, err := r.Cookie(middleware.RefreshTokenCookie)
+	if err != nil || cookie.Value == "" {
+		writeError(w, http.StatusBadRequest, "refresh_token is required")
+		return "", false, false
+	}
+	if !middleware.ValidCSRF(r) {
+		writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+		return "", false, false
+	}
+	return cookie.Value, true, true
+}
@@ -0,0 +1,105 @@
+/**
+ * response.go - Shared helpers for JSON API handlers
+ *
+ * Small utilities used by the REST handlers to keep request parsing,
+ * authentication checks and JSON responses consistent across endpoints.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/user/web-app/internal/middleware"
+)
+
+/**
+ * ErrorResponse - JSON error body returned by API handlers
+ */
+type ErrorResponse struct {
+	Error string `json:"error"` // Human-readable error message
+}
+
+/**
+ * writeJSON - Encodes a value as JSON with the given status code
+ *
+ * @param w HTTP response writer
+ * @param status HTTP status code
+ * @param v Value to encode
+ */
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+/**
+ * writeError - Writes a JSON error response
+ *
+ * @param w HTTP response writer
+ * @param status HTTP status code
+ * @param message Error message shown to the client
+ */
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
+
+/**
+ * decodeJSON - Decodes the request body into v
+ *
+ * Writes a 400 response and returns false if the body is not valid JSON.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request
+ * @param v Destination for the decoded body
+ * @return true if decoding succeeded
+ */
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+/**
+ * requireUser - Returns the authenticated user or writes a 401
+ *
+ * Handlers for protected endpoints call this first; a nil result means
+ * the response has already been written.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request (with context from JWT middleware)
+ * @return UserClaims if authenticated, nil otherwise
+ */
+func requireUser(w http.ResponseWriter, r *http.Request) *middleware.UserClaims {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+	}
+	return user
+}
+
+/**
+ * pathID - Parses a numeric path parameter
+ *
+ * Writes a 400 response and returns false if the value is missing or invalid.
+ *
+ * @param w HTTP response writer
+ * @param r HTTP request
+ * @param name Path wildcard name (e.g. "id" for /api/servers/{id})
+ * @return Parsed ID and whether parsing succeeded
+ */
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,494 @@
+/**
+ * role.go - Server Role Handler
+ *
+ * REST endpoints for managing a server's roles and assigning them to
+ * members. Every server has an @everyone role (position 0) that applies
+ * to all members; it can be edited but not renamed, deleted or assigned.
+ *
+ * Access:
+ * - Any member can list roles
+ * - Everything else requires Manage Roles, and the target role must rank
+ *   strictly below the caller's highest role
+ * - Members can never grant permissions they do not have themselves
+ *
+ * Endpoints:
+ * - GET    /api/servers/{id}/roles:                           List roles (highest first)
+ * - POST   /api/servers/{id}/roles:                           Create a role
+ * - PUT    /api/servers/{id}/roles/positions:                 Atomically reorder roles
+ * - PATCH  /api/servers/{id}/roles/{roleID}:                  Edit name, color or permissions
+ * - DELETE /api/servers/{id}/roles/{roleID}:                  Delete a role
+ * - PUT    /api/servers/{id}/members/{userID}/roles/{roleID}: Assign a role to a member
+ * - DELETE /api/servers/{id}/members/{userID}/roles/{roleID}: Remove a role from a member
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+const (
+	maxRoleNameLength = 100      // Matches roles.name VARCHAR(100)
+	maxRoleColor      = 0xFFFFFF // Colors are 24-bit RGB
+)
+
+/**
+ * RoleHandler - Handler for role endpoints
+ */
+type RoleHandler struct {
+	roleService   *models.RoleService   // Database service for role operations
+	memberService *models.MemberService // Used to load members after role changes
+	hub           *gateway.Hub          // Real-time event fan-out
+}
+
+/**
+ * RoleRequest - Request body for creating or editing a role
+ *
+ * Fields are pointers so PATCH requests can distinguish between
+ * "not provided" and "set to zero".
+ */
+type RoleRequest struct {
+	Name        *string                 `json:"name"`        // Role display name
+	Color       *int                    `json:"color"`       // RGB color, 0 for none
+	Permissions *permissions.Permission `json:"permissions"` // Permission bitfield
+}
+
+/**
+ * RoleDeleteEvent - Gateway data for SERVER_ROLE_DELETE
+ */
+type RoleDeleteEvent struct {
+	ServerID int `json:"server_id"` // Server the role belonged to
+	RoleID   int `json:"role_id"`   // Deleted role ID
+}
+
+/**
+ * NewRoleHandler - Constructor for RoleHandler
+ *
+ * @param db Database connection for role operations
+ * @param hub Gateway hub for publishing role events
+ * @return Configured RoleHandler instance
+ */
+func NewRoleHandler(db *sql.DB, hub *gateway.Hub) *RoleHandler {
+	return &RoleHandler{
+		roleService:   models.NewRoleService(db),
+		memberService: models.NewMemberService(db),
+		hub:           hub,
+	}
+}
+
+/**
+ * ListRoles - Lists a server's roles, highest position first (members only)
+ */
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireMember(w, r, h.roleService, serverID, user.UserID); !ok {
+		return
+	}
+
+	roles, err := h.roleService.ListRoles(r.Context(), serverID)
+	if err != nil {
+		log.Printf("Failed to list roles for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+/**
+ * CreateRole - Creates a role directly above @everyone (Manage Roles)
+ */
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == nil {
+		writeError(w, http.StatusBadRequest, "Role name is required")
+		return
+	}
+	if !validateRoleRequest(w, &req) {
+		return
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	// New roles land at position 1, so the caller must outrank that slot
+	if !perms.CanManage(1) {
+		writeError(w, http.StatusForbidden, "You need a role above @everyone to create roles")
+		return
+	}
+
+	var color int
+	if req.Color != nil {
+		color = *req.Color
+	}
+	var granted permissions.Permission
+	if req.Permissions != nil {
+		granted = *req.Permissions
+	}
+	if !perms.Permissions.Has(granted) {
+		writeError(w, http.StatusForbidden, "You cannot grant permissions you do not have")
+		return
+	}
+
+	role, err := h.roleService.CreateRole(r.Context(), serverID, *req.Name, color, granted)
+	if err != nil {
+		log.Printf("Failed to create role in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create role")
+		return
+	}
+
+	log.Printf("User %d created role %d (%s) in server %d", user.UserID, role.ID, role.Name, serverID)
+	h.hub.Publish(gateway.EventRoleCreate, serverID, role)
+	writeJSON(w, http.StatusCreated, role)
+}
+
+/**
+ * UpdateRole - Edits a role's name, color and/or permissions (Manage Roles)
+ */
+func (h *RoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "roleID")
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !validateRoleRequest(w, &req) {
+		return
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	role, ok := h.requireManageableRole(w, r, perms, serverID, roleID)
+	if !ok {
+		return
+	}
+	if role.IsDefault && req.Name != nil {
+		writeError(w, http.StatusBadRequest, "The @everyone role cannot be renamed")
+		return
+	}
+	if req.Permissions != nil && !perms.Permissions.Has(*req.Permissions) {
+		writeError(w, http.StatusForbidden, "You cannot grant permissions you do not have")
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(r.Context(), serverID, roleID, models.RoleUpdate{
+		Name:        req.Name,
+		Color:       req.Color,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		h.handleLookupError(w, roleID, err)
+		return
+	}
+
+	log.Printf("User %d updated role %d in server %d", user.UserID, roleID, serverID)
+	h.hub.Publish(gateway.EventRoleUpdate, serverID, role)
+	writeJSON(w, http.StatusOK, role)
+}
+
+/**
+ * DeleteRole - Deletes a role and removes it from every member (Manage Roles)
+ */
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "roleID")
+	if !ok {
+		return
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	role, ok := h.requireManageableRole(w, r, perms, serverID, roleID)
+	if !ok {
+		return
+	}
+	if role.IsDefault {
+		writeError(w, http.StatusBadRequest, "The @everyone role cannot be deleted")
+		return
+	}
+
+	if err := h.roleService.DeleteRole(r.Context(), serverID, roleID); err != nil {
+		h.handleLookupError(w, roleID, err)
+		return
+	}
+
+	log.Printf("User %d deleted role %d from server %d", user.UserID, roleID, serverID)
+	h.hub.Publish(gateway.EventRoleDelete, serverID, RoleDeleteEvent{ServerID: serverID, RoleID: roleID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * ReorderRoles - Updates positions of several roles at once
+ *
+ * Expects a JSON array of {id, position}. Every role being moved must
+ * rank below the caller both before and after the move, and positions
+ * must be at least 1 so nothing sits level with @everyone. Either every
+ * update is applied or none are.
+ */
+func (h *RoleHandler) ReorderRoles(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var positions []models.RolePosition
+	if !decodeJSON(w, r, &positions) {
+		return
+	}
+	if len(positions) == 0 {
+		writeError(w, http.StatusBadRequest, "At least one role position is required")
+		return
+	}
+	for _, p := range positions {
+		if p.Position < 1 {
+			writeError(w, http.StatusBadRequest, "Role positions must be at least 1")
+			return
+		}
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+
+	existing, err := h.roleService.ListRoles(r.Context(), serverID)
+	if err != nil {
+		log.Printf("Failed to list roles for server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	current := make(map[int]int, len(existing))
+	for _, role := range existing {
+		current[role.ID] = role.Position
+	}
+	for _, p := range positions {
+		position, found := current[p.ID]
+		if !found {
+			writeError(w, http.StatusBadRequest, "All roles must belong to this server")
+			return
+		}
+		if !perms.CanManage(position) || !perms.CanManage(p.Position) {
+			writeError(w, http.StatusForbidden, "You can only move roles below your highest role")
+			return
+		}
+	}
+
+	roles, err := h.roleService.ReorderRoles(r.Context(), serverID, positions)
+	if err != nil {
+		if err == models.ErrRoleNotInServer {
+			writeError(w, http.StatusBadRequest, "All roles must belong to this server")
+			return
+		}
+		log.Printf("Failed to reorder roles in server %d: %v", serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to reorder roles")
+		return
+	}
+
+	for _, role := range roles {
+		h.hub.Publish(gateway.EventRoleUpdate, serverID, role)
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+/**
+ * AddMemberRole - Assigns a role to a member (Manage Roles)
+ */
+func (h *RoleHandler) AddMemberRole(w http.ResponseWriter, r *http.Request) {
+	h.changeMemberRole(w, r, true)
+}
+
+/**
+ * RemoveMemberRole - Removes a role from a member (Manage Roles)
+ */
+func (h *RoleHandler) RemoveMemberRole(w http.ResponseWriter, r *http.Request) {
+	h.changeMemberRole(w, r, false)
+}
+
+/**
+ * changeMemberRole - Shared implementation of role assignment and removal
+ *
+ * Both operations are idempotent. On success the updated member is
+ * returned and published as SERVER_MEMBER_UPDATE.
+ *
+ * @param assign true to assign the role, false to remove it
+ */
+func (h *RoleHandler) changeMemberRole(w http.ResponseWriter, r *http.Request, assign bool) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	serverID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := pathID(w, r, "userID")
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "roleID")
+	if !ok {
+		return
+	}
+
+	perms, ok := requirePermission(w, r, h.roleService, serverID, user.UserID, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+	role, ok := h.requireManageableRole(w, r, perms, serverID, roleID)
+	if !ok {
+		return
+	}
+	if role.IsDefault {
+		writeError(w, http.StatusBadRequest, "The @everyone role cannot be assigned or removed")
+		return
+	}
+
+	var err error
+	if assign {
+		err = h.roleService.AssignRole(r.Context(), serverID, targetID, roleID)
+	} else {
+		err = h.roleService.UnassignRole(r.Context(), serverID, targetID, roleID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to change role %d for user %d in server %d: %v", roleID, targetID, serverID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update member roles")
+		return
+	}
+
+	member, err := h.memberService.GetMember(r.Context(), serverID, targetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Member not found")
+			return
+		}
+		log.Printf("Failed to load member %d in server %d: %v", targetID, serverID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("User %d changed role %d for user %d in server %d", user.UserID, roleID, targetID, serverID)
+	h.hub.Publish(gateway.EventMemberUpdate, serverID, member)
+	writeJSON(w, http.StatusOK, member)
+}
+
+/**
+ * requireManageableRole - Loads a role the caller outranks
+ *
+ * Writes 404 if the role is not in the server and 403 if it ranks at or
+ * above the caller's highest role.
+ *
+ * @param perms Caller's effective permissions
+ * @return The role and whether the check passed
+ */
+func (h *RoleHandler) requireManageableRole(w http.ResponseWriter, r *http.Request, perms permissions.Result, serverID, roleID int) (*models.Role, bool) {
+	role, err := h.roleService.GetRole(r.Context(), serverID, roleID)
+	if err != nil {
+		h.handleLookupError(w, roleID, err)
+		return nil, false
+	}
+	if !perms.CanManage(role.Position) {
+		writeError(w, http.StatusForbidden, "You can only manage roles below your highest role")
+		return nil, false
+	}
+	return role, true
+}
+
+/**
+ * handleLookupError - Maps role lookup errors to HTTP responses
+ */
+func (h *RoleHandler) handleLookupError(w http.ResponseWriter, roleID int, err error) {
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Role not found")
+		return
+	}
+	log.Printf("Database error for role %d: %v", roleID, err)
+	writeError(w, http.StatusInternalServerError, "Database error")
+}
+
+/**
+ * validateRoleRequest - Trims and validates the provided role fields
+ *
+ * @return Whether the request is valid
+ */
+func validateRoleRequest(w http.ResponseWriter, req *RoleRequest) bool {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "Role name cannot be empty")
+			return false
+		}
+		if len([]rune(name)) > maxRoleNameLength {
+			writeError(w, http.StatusBadRequest, "Role name is too long")
+			return false
+		}
+		if name == models.DefaultRoleName {
+			writeError(w, http.StatusBadRequest, "That role name is reserved")
+			return false
+		}
+		req.Name = &name
+	}
+	if req.Color != nil && (*req.Color < 0 || *req.Color > maxRoleColor) {
+		writeError(w, http.StatusBadRequest, "Role color must be an RGB value between 0 and 16777215")
+		return false
+	}
+	if req.Permissions != nil && !req.Permissions.Valid() {
+		writeError(w, http.StatusBadRequest, "Unknown permission bits")
+		return false
+	}
+	return true
+}
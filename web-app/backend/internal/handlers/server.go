@@ -0,0 +1,311 @@
+/**
+ * server.go - Server (guild) Management Handler
+ *
+ * REST endpoints for creating and managing Discord-style servers.
+ * All endpoints require authentication. Editing a server requires Manage
+ * Server; deleting it is checked against servers.owner_id using the user
+ * injected by the JWT middleware.
+ *
+ * Only the owner can turn on mfa_required (2FA for moderation, see the
+ * permissions package), and only while they have 2FA enabled themselves.
+ *
+ * Successful mutations are published to the gateway so connected
+ * clients see server changes without polling.
+ *
+ * Endpoints:
+ * - POST   /api/servers:      Create a server owned by the current user
+ * - GET    /api/servers:      List servers the current user owns or has joined
+ * - GET    /api/servers/{id}: Get a single server (members only)
+ * - PATCH  /api/servers/{id}: Update name/icon (Manage Server), mfa_required (owner)
+ * - DELETE /api/servers/{id}: Delete a server (owner only)
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+	"github.com/user/web-app/internal/permissions"
+)
+
+// maxServerNameLength matches servers.name VARCHAR(100)
+const maxServerNameLength = 100
+
+/**
+ * ServerHandler - Handler for server management endpoints
+ */
+type ServerHandler struct {
+	serverService *models.ServerService // Database service for server operations
+	roleService   *models.RoleService   // Used for permission checks
+	userService   *models.UserService   // Used to check the owner's 2FA
+	hub           *gateway.Hub          // Real-time event fan-out
+}
+
+/**
+ * ServerRequest - Request body for creating or updating a server
+ *
+ * Fields are pointers so PATCH requests can distinguish between
+ * "not provided" and "set to empty".
+ */
+type ServerRequest struct {
+	Name        *string `json:"name"`         // Server display name
+	IconURL     *string `json:"icon_url"`     // Optional icon image URL
+	MFARequired *bool   `json:"mfa_required"` // Require 2FA for moderation (owner only)
+}
+
+/**
+ * NewServerHandler - Constructor for ServerHandler
+ *
+ * @param db Database connection for server operations
+ * @param hub Gateway hub for publishing server events
+ * @return Configured ServerHandler instance
+ */
+func NewServerHandler(db *sql.DB, hub *gateway.Hub) *ServerHandler {
+	return &ServerHandler{
+		serverService: models.NewServerService(db),
+		roleService:   models.NewRoleService(db),
+		userService:   models.NewUserService(db),
+		hub:           hub,
+	}
+}
+
+/**
+ * CreateServer - Creates a new server owned by the current user
+ *
+ * The server gets an @everyone role and the owner is added to server_members.
+ */
+func (h *ServerHandler) CreateServer(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var req ServerRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Name == nil {
+		writeError(w, http.StatusBadRequest, "Server name is required")
+		return
+	}
+	name, ok := validateServerName(w, *req.Name)
+	if !ok {
+		return
+	}
+
+	server, err := h.serverService.CreateServer(r.Context(), name, user.UserID, req.IconURL)
+	if err != nil {
+		log.Printf("Failed to create server: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create server")
+		return
+	}
+
+	log.Printf("User %d created server %d (%s)", user.UserID, server.ID, server.Name)
+	h.hub.Subscribe(user.UserID, server.ID)
+	h.hub.Publish(gateway.EventServerCreate, server.ID, server)
+	writeJSON(w, http.StatusCreated, server)
+}
+
+/**
+ * ListMyServers - Lists servers the current user owns or has joined
+ */
+func (h *ServerHandler) ListMyServers(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	servers, err := h.serverService.GetServersForUser(r.Context(), user.UserID)
+	if err != nil {
+		log.Printf("Failed to list servers for user %d: %v", user.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list servers")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, servers)
+}
+
+/**
+ * GetServer - Returns a single server
+ *
+ * Only members (including the owner) can see a server. Non-members get a
+ * 404 rather than a 403 so server IDs cannot be probed.
+ */
+func (h *ServerHandler) GetServer(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, ok := requireMember(w, r, h.roleService, id, user.UserID); !ok {
+		return
+	}
+
+	server, err := h.serverService.GetServerByID(r.Context(), id)
+	if err != nil {
+		h.handleLookupError(w, id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, server)
+}
+
+/**
+ * UpdateServer - Updates a server's name and/or icon (Manage Server)
+ */
+func (h *ServerHandler) UpdateServer(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req ServerRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil {
+		name, ok := validateServerName(w, *req.Name)
+		if !ok {
+			return
+		}
+		req.Name = &name
+	}
+
+	if _, ok := requirePermission(w, r, h.roleService, id, user.UserID, permissions.ManageServer); !ok {
+		return
+	}
+	if req.MFARequired != nil && !h.canChangeMFARequirement(w, r, id, user.UserID, *req.MFARequired) {
+		return
+	}
+
+	server, err := h.serverService.UpdateServer(r.Context(), id, req.Name, req.IconURL, req.MFARequired)
+	if err != nil {
+		h.handleLookupError(w, id, err)
+		return
+	}
+
+	log.Printf("User %d updated server %d", user.UserID, server.ID)
+	h.hub.Publish(gateway.EventServerUpdate, server.ID, server)
+	writeJSON(w, http.StatusOK, server)
+}
+
+/**
+ * DeleteServer - Deletes a server and (via cascade) its channels and messages
+ */
+func (h *ServerHandler) DeleteServer(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.requireOwner(w, r, id, user.UserID) {
+		return
+	}
+
+	if err := h.serverService.DeleteServer(r.Context(), id); err != nil {
+		h.handleLookupError(w, id, err)
+		return
+	}
+
+	log.Printf("User %d deleted server %d", user.UserID, id)
+	h.hub.Publish(gateway.EventServerDelete, id, map[string]int{"id": id})
+	h.hub.RemoveServer(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * requireOwner - Checks that the user owns the server
+ *
+ * Writes 404 if the server does not exist and 403 if the user is not the owner.
+ *
+ * @return true if the user owns the server
+ */
+func (h *ServerHandler) requireOwner(w http.ResponseWriter, r *http.Request, serverID, userID int) bool {
+	server, err := h.serverService.GetServerByID(r.Context(), serverID)
+	if err != nil {
+		h.handleLookupError(w, serverID, err)
+		return false
+	}
+	if server.OwnerID != userID {
+		writeError(w, http.StatusForbidden, "Only the server owner can do that")
+		return false
+	}
+	return true
+}
+
+/**
+ * canChangeMFARequirement - Checks that the user may set mfa_required
+ *
+ * Only the owner can change it, and turning it on requires the owner to
+ * have 2FA so they cannot lock moderation behind something they lack.
+ *
+ * @return true if the change is allowed
+ */
+func (h *ServerHandler) canChangeMFARequirement(w http.ResponseWriter, r *http.Request, serverID, userID int, required bool) bool {
+	if !h.requireOwner(w, r, serverID, userID) {
+		return false
+	}
+	if !required {
+		return true
+	}
+
+	owner, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !owner.MFAEnabled {
+		writeError(w, http.StatusForbidden, "Enable two-factor authentication before requiring it")
+		return false
+	}
+	return true
+}
+
+/**
+ * handleLookupError - Maps server lookup errors to HTTP responses
+ */
+func (h *ServerHandler) handleLookupError(w http.ResponseWriter, serverID int, err error) {
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Server not found")
+		return
+	}
+	log.Printf("Database error for server %d: %v", serverID, err)
+	writeError(w, http.StatusInternalServerError, "Database error")
+}
+
+/**
+ * validateServerName - Trims and validates a server name
+ *
+ * @return Trimmed name and whether it is valid
+ */
+func validateServerName(w http.ResponseWriter, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "Server name cannot be empty")
+		return "", false
+	}
+	if len([]rune(name)) > maxServerNameLength {
+		writeError(w, http.StatusBadRequest, "Server name is too long")
+		return "", false
+	}
+	return name, true
+}
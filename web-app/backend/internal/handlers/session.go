@@ -0,0 +1,144 @@
+/**
+ * session.go - Session and Device Management Handler
+ *
+ * Every sign-in (Google or password) starts a session that records the
+ * device name, IP address and User-Agent it came from; refreshing tokens
+ * keeps the session. Access tokens carry the session ID in their
+ * "jti" claim, so revoking a session immediately invalidates its refresh
+ * tokens and, through the middleware session check, its access tokens.
+ *
+ * Endpoints:
+ * - GET    /api/users/@me/sessions:             List the current user's active sessions
+ * - DELETE /api/users/@me/sessions/{sessionID}: Revoke one of them (may be the current one)
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/middleware"
+	"github.com/user/web-app/internal/models"
+)
+
+/**
+ * SessionHandler - Handler for session management endpoints
+ */
+type SessionHandler struct {
+	sessionService *models.SessionService    // Database service for session operations
+	auth           *middleware.Authenticator // Session cache to drop revoked sessions from
+	hub            *gateway.Hub              // Gateway connections to drop on revocation
+}
+
+/**
+ * NewSessionHandler - Constructor for SessionHandler
+ *
+ * @param db Database connection for session operations
+ * @param auth Token verification shared with JWTMiddleware
+ * @param hub Gateway hub whose connections end with their session
+ * @return Configured SessionHandler instance
+ */
+func NewSessionHandler(db *sql.DB, auth *middleware.Authenticator, hub *gateway.Hub) *SessionHandler {
+	return &SessionHandler{
+		sessionService: models.NewSessionService(db),
+		auth:           auth,
+		hub:            hub,
+	}
+}
+
+/**
+ * ListSessions - Lists the current user's active sessions
+ *
+ * The session the request was made with has "current": true.
+ */
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(r.Context(), user.UserID)
+	if err != nil {
+		log.Printf("Failed to list sessions for user %d: %v", user.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == user.SessionID()
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+/**
+ * RevokeSession - Revokes one of the current user's sessions
+ *
+ * Returns 404 for sessions that are unknown, belong to someone else or
+ * were already revoked.
+ */
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	sessionID := r.PathValue("sessionID")
+
+	if err := h.sessionService.RevokeSession(r.Context(), user.UserID, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		log.Printf("Failed to revoke session for user %d: %v", user.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	endSession(h.auth, h.hub, sessionID)
+
+	log.Printf("User %d revoked session %s", user.UserID, sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * endSession - Makes a revoked session's credentials stop working at once
+ *
+ * Drops the session from the token middleware's cache so its access
+ * tokens are rejected, and closes gateway connections that identified
+ * with it.
+ *
+ * @param auth Token verification whose session cache to update
+ * @param hub Gateway hub
+ * @param sessionID Revoked session ID; empty is ignored
+ */
+func endSession(auth *middleware.Authenticator, hub *gateway.Hub, sessionID string) {
+	auth.ForgetSession(sessionID)
+	hub.EndAuthSession(sessionID)
+}
+
+/**
+ * clientIP - Returns the IP address a request came from
+ *
+ * Uses the connection's remote address; forwarding headers are not
+ * trusted because clients can set them freely.
+ */
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/**
+ * truncate - Shortens s to at most n characters
+ */
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,245 @@
+/**
+ * user.go - Current User Profile Handler
+ *
+ * Every user has a unique handle (username) and an optional display name:
+ * - Usernames are 2-32 characters of lowercase letters, digits, "_" and
+ *   "." with no consecutive periods, and are unique
+ * - Display names are free-form, up to 32 characters, and need not be
+ *   unique; clients show the username when it is null
+ *
+ * Usernames can be changed once per models.UsernameChangeCooldown; early
+ * attempts get 429 with a Retry-After header. Changes are sent to the
+ * user's other gateway sessions as USER_UPDATE.
+ *
+ * Endpoints:
+ * - GET   /api/users/@me:          Get the current user
+ * - PATCH /api/users/@me:          Update the display name
+ * - PUT   /api/users/@me/username: Change the username
+ */
+
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/web-app/internal/gateway"
+	"github.com/user/web-app/internal/models"
+)
+
+const (
+	minUsernameLength    = 2
+	maxUsernameLength    = 32 // users.username is VARCHAR(32)
+	maxDisplayNameLength = 32
+)
+
+// validUsername matches the allowed username characters and length.
+var validUsername = regexp.MustCompile(`^[a-z0-9_.]{2,32}$`)
+
+/**
+ * UserHandler - Handler for the current user's profile
+ */
+type UserHandler struct {
+	userService *models.UserService // Database service for user operations
+	hub         *gateway.Hub        // Real-time event fan-out
+}
+
+/**
+ * UpdateUserRequest - Request body for PATCH /api/users/@me
+ */
+type UpdateUserRequest struct {
+	DisplayName *string `json:"display_name"` // New display name; "" clears it
+}
+
+/**
+ * ChangeUsernameRequest - Request body for PUT /api/users/@me/username
+ */
+type ChangeUsernameRequest struct {
+	Username string `json:"username"` // New unique handle
+}
+
+/**
+ * NewUserHandler - Constructor for UserHandler
+ *
+ * @param db Database connection for user operations
+ * @param hub Gateway hub for publishing user events
+ * @return Configured UserHandler instance
+ */
+func NewUserHandler(db *sql.DB, hub *gateway.Hub) *UserHandler {
+	return &UserHandler{
+		userService: models.NewUserService(db),
+		hub:         hub,
+	}
+}
+
+/**
+ * GetCurrentUser - Returns the authenticated user's profile
+ */
+func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+
+	user, err := h.userService.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		log.Printf("Failed to load user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+/**
+ * UpdateCurrentUser - Updates the authenticated user's display name
+ */
+func (h *UserHandler) UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req UpdateUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.DisplayName == nil {
+		writeError(w, http.StatusBadRequest, "display_name is required")
+		return
+	}
+	displayName, ok := normalizeDisplayName(*req.DisplayName)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Display name must be at most 32 characters")
+		return
+	}
+
+	user, err := h.userService.UpdateDisplayName(r.Context(), claims.UserID, displayName)
+	if err != nil {
+		log.Printf("Failed to update display name for user %d: %v", claims.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	h.hub.PublishUsers(gateway.EventUserUpdate, []int{user.ID}, user)
+	writeJSON(w, http.StatusOK, user)
+}
+
+/**
+ * ChangeUsername - Changes the authenticated user's username
+ *
+ * Returns 400 for invalid usernames, 409 if it is taken and 429 (with
+ * Retry-After in seconds) within the cooldown after the last change.
+ */
+func (h *UserHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req ChangeUsernameRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	username, ok := checkUsername(req.Username)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Username must be 2-32 characters of a-z, 0-9, _ and .")
+		return
+	}
+
+	user, retryAfter, err := h.userService.ChangeUsername(r.Context(), claims.UserID, username)
+	if err != nil {
+		switch err {
+		case models.ErrUsernameTaken:
+			writeError(w, http.StatusConflict, "Username is already taken")
+		case models.ErrUsernameChangeTooSoon:
+			seconds := int(retryAfter.Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeError(w, http.StatusTooManyRequests, "You are changing your username too fast")
+		default:
+			log.Printf("Failed to change username for user %d: %v", claims.UserID, err)
+			writeError(w, http.StatusInternalServerError, "Failed to change username")
+		}
+		return
+	}
+
+	log.Printf("User %d changed username to %s", user.ID, user.Username)
+	h.hub.PublishUsers(gateway.EventUserUpdate, []int{user.ID}, user)
+	writeJSON(w, http.StatusOK, user)
+}
+
+/**
+ * checkUsername - Validates a requested username
+ *
+ * Input is trimmed and lowercased, so "Alice" is accepted as "alice".
+ *
+ * @return The username and whether it is valid
+ */
+func checkUsername(username string) (string, bool) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !validUsername.MatchString(username) || strings.Contains(username, "..") {
+		return "", false
+	}
+	return username, true
+}
+
+/**
+ * normalizeDisplayName - Trims a display name and checks its length
+ *
+ * @return The display name ("" for none) and whether it is valid
+ */
+func normalizeDisplayName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	return name, utf8.RuneCountInString(name) <= maxDisplayNameLength
+}
+
+/**
+ * usernameFrom - Derives a valid username from arbitrary text
+ *
+ * Used for accounts created through login providers, whose names can be
+ * anything. Spaces and dashes become "_", other characters outside the
+ * allowed set are dropped and the result leaves room for a discriminator.
+ * Returns "" if too little is left.
+ */
+func usernameFrom(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == '.':
+			if !strings.HasSuffix(b.String(), ".") {
+				b.WriteRune(r)
+			}
+		case r == ' ' || r == '-':
+			if !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+		}
+	}
+
+	username := truncate(b.String(), maxUsernameLength-len("_0000"))
+	if len(username) < minUsernameLength {
+		return ""
+	}
+	return username
+}
+
+/**
+ * withDiscriminator - Appends a random 4-digit suffix to a username
+ *
+ * Used to resolve collisions, e.g. "john_smith" -> "john_smith_0427".
+ */
+func withDiscriminator(username string) string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(10000))
+	return fmt.Sprintf("%s_%04d", username, n.Int64())
+}
@@ -11,9 +11,11 @@
  * - User context injection for authenticated requests
  * - Comprehensive error logging for debugging
  * - Support for Bearer token format
+ * - HttpOnly cookie authentication with CSRF protection (see cookies.go)
  * 
  * Usage:
- * mux.Handle("/api/protected", middleware.JWTMiddleware(handler))
+ * auth := middleware.NewAuthenticator(middleware.AuthConfig{Tokens: tokenService})
+ * mux.Handle("/api/protected", auth.JWTMiddleware(handler))
  * 
  * The middleware makes authentication optional - if no token is provided
  * or token is invalid, the request continues but without user context.
@@ -24,27 +26,62 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/user/web-app/internal/tokens"
 )
 
 /**
  * UserClaims - JWT token claims structure
  * 
- * Defines the structure of claims stored in JWT tokens.
- * Embeds jwt.RegisteredClaims for standard JWT fields (exp, iat, etc.)
- * and adds custom fields for user identification.
+ * Defined by the tokens package, which issues and verifies every token.
+ * Contains the user's ID, email and username plus the standard claims;
+ * the "jti" claim holds the session ID (see SessionID).
  */
-type UserClaims struct {
-	UserID   int    `json:"user_id"`  // Database user ID
-	Email    string `json:"email"`    // User email address
-	Username string `json:"username"` // User display name
-	jwt.RegisteredClaims              // Standard JWT claims (exp, iat, etc.)
+type UserClaims = tokens.Claims
+
+/**
+ * AuthConfig - Settings for NewAuthenticator
+ */
+type AuthConfig struct {
+	Tokens        *tokens.Service // Verifies access tokens; shared with AuthHandler
+	Sessions      SessionStore    // Rejects tokens of revoked sessions; nil disables the check
+	SessionTTL    time.Duration   // How long an active session is trusted without a lookup
+	SecureCookies bool            // Mark auth cookies Secure; disable only for plain-HTTP development
+}
+
+/**
+ * Authenticator - Verifies access tokens and manages auth cookies
+ * 
+ * Create one at startup and share it between JWTMiddleware, the auth
+ * handlers and the WebSocket gateway so all of them accept exactly the
+ * same tokens.
+ */
+type Authenticator struct {
+	tokens        *tokens.Service
+	sessions      *sessionCache // nil when session checks are disabled
+	secureCookies bool
+}
+
+/**
+ * NewAuthenticator - Constructor for Authenticator
+ * 
+ * @param cfg Token service, session store and cookie settings
+ * @return Configured Authenticator instance
+ */
+func NewAuthenticator(cfg AuthConfig) *Authenticator {
+	a := &Authenticator{
+		tokens:        cfg.Tokens,
+		secureCookies: cfg.SecureCookies,
+	}
+	if cfg.Sessions != nil {
+		a.sessions = newSessionCache(cfg.Sessions, cfg.SessionTTL)
+	}
+	return a
 }
 
 /**
@@ -59,13 +96,15 @@ const UserContextKey contextKey = "user"
 /**
  * JWTMiddleware - JWT token validation middleware
  * 
- * This middleware extracts and validates JWT tokens from Authorization headers.
- * It implements optional authentication - requests continue even without valid tokens,
+ * This middleware extracts and validates JWT tokens from Authorization headers,
+ * or from the access_token cookie when no header is sent (see cookies.go for
+ * the precedence rules). It implements optional authentication - requests continue even without valid tokens,
  * but authenticated users get their information added to the request context.
  * 
  * Process:
- * 1. Extract Authorization header
- * 2. Validate Bearer token format
+ * 1. Extract Authorization header, falling back to the access_token cookie
+ * 2. Validate Bearer token format, or the CSRF token for cookies (403 if
+ *    a mutating request's X-CSRF-Token header is missing or wrong)
  * 3. Parse and verify JWT signature
  * 4. Add user claims to request context if valid
  * 5. Continue to next handler regardless of auth status
@@ -78,62 +117,90 @@ const UserContextKey contextKey = "user"
  * @param next The next HTTP handler in the chain
  * @return HTTP handler that includes JWT validation
  */
-func JWTMiddleware(next http.Handler) http.Handler {
+func (a *Authenticator) JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Step 1: Extract Authorization header
 		authHeader := r.Header.Get("Authorization")
+		var tokenString string
 		if authHeader == "" {
-			// No token provided - continue without user context
-			// This allows public endpoints to work normally
-			log.Printf("No Authorization header found for %s", r.URL.Path)
-			next.ServeHTTP(w, r)
-			return
-		}
+			tokenString = CookieToken(r)
+			if tokenString == "" {
+				// No token provided - continue without user context
+				// This allows public endpoints to work normally
+				log.Printf("No Authorization header found for %s", r.URL.Path)
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		// Debug logging (truncated for security)
-		log.Printf("Authorization header found for %s: %s", r.URL.Path, authHeader[:20]+"...")
+			// Step 2: Cookies are sent by the browser automatically, so
+			// mutating requests must prove they came from our frontend
+			if !ValidCSRF(r) {
+				log.Printf("Missing or invalid CSRF token for %s %s", r.Method, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":"Missing or invalid CSRF token"}` + "\n"))
+				return
+			}
+		} else {
+			// Debug logging (truncated for security)
+			log.Printf("Authorization header found for %s: %s", r.URL.Path, authHeader[:min(len(authHeader), 20)]+"...")
 
-		// Step 2: Validate Bearer token format
-		// Expected format: "Bearer <jwt-token>"
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			// Invalid format (no "Bearer " prefix)
-			log.Printf("Invalid token format (missing Bearer prefix)")
-			next.ServeHTTP(w, r)
-			return
+			// Step 2: Validate Bearer token format
+			// Expected format: "Bearer <jwt-token>"
+			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				// Invalid format (no "Bearer " prefix)
+				log.Printf("Invalid token format (missing Bearer prefix)")
+				next.ServeHTTP(w, r)
+				return
+			}
 		}
 
 		// Step 3: Parse and verify JWT token
-		token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Verify the signing method is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			// Return the secret key for signature verification
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := a.ParseToken(r.Context(), tokenString)
+		if err != nil {
 			// Invalid token - log and continue without user context
 			log.Printf("Invalid token for %s: %v", r.URL.Path, err)
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Step 4: Extract user claims and add to context
-		if claims, ok := token.Claims.(*UserClaims); ok {
-			// Token is valid - add user info to request context
-			log.Printf("User authenticated for %s: %s (ID: %d)", r.URL.Path, claims.Username, claims.UserID)
-			ctx := context.WithValue(r.Context(), UserContextKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			// Claims parsing failed
-			log.Printf("Failed to parse token claims for %s", r.URL.Path)
-			next.ServeHTTP(w, r)
-		}
+		// Step 4: Token is valid - add user info to request context
+		log.Printf("User authenticated for %s: %s (ID: %d)", r.URL.Path, claims.Username, claims.UserID)
+		ctx := context.WithValue(r.Context(), UserContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+/**
+ * ParseToken - Parses and verifies a JWT token string
+ * 
+ * Shared by JWTMiddleware and the WebSocket gateway so both accept exactly
+ * the same tokens. Signatures are checked by AuthConfig.Tokens, and when
+ * AuthConfig.Sessions is set the token's session must still be active.
+ * 
+ * @param ctx Bounds the session lookup
+ * @param tokenString Raw JWT (without "Bearer " prefix)
+ * @return UserClaims if the token is valid
+ * @return error if the signature, algorithm, expiry or session is invalid
+ */
+func (a *Authenticator) ParseToken(ctx context.Context, tokenString string) (*UserClaims, error) {
+	if a.tokens == nil {
+		return nil, errors.New("token service not configured")
+	}
+
+	claims, err := a.tokens.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.CheckSession(ctx, claims.SessionID()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
 /**
  * GetUserFromContext - Retrieves user claims from request context
  * 
@@ -0,0 +1,141 @@
+/**
+ * cookies.go - Cookie Authentication and CSRF Protection
+ *
+ * Besides "Authorization: Bearer" headers, browsers can authenticate with
+ * cookies the page's JavaScript cannot read. A client opts in by sending
+ * "X-Auth-Mode: cookie" when signing in (see handlers.AuthResponse); the
+ * tokens are then set as cookies instead of being returned in the body:
+ * - access_token:  HttpOnly, Path=/, lives as long as the access token
+ * - refresh_token: HttpOnly, Path=/auth, used by /auth/refresh and /auth/logout
+ * - csrf_token:    Readable by JavaScript, also returned in the response body
+ *
+ * All three are SameSite=Strict and Secure unless AuthConfig.SecureCookies
+ * is false for plain-HTTP development.
+ *
+ * CSRF (double submit): requests authenticated by cookie with a method
+ * other than GET, HEAD or OPTIONS must repeat the csrf_token value in the
+ * X-CSRF-Token header. Another site can make the browser send cookies but
+ * can neither read them nor set custom headers.
+ *
+ * Precedence: an Authorization header always wins. When one is present
+ * the cookies are ignored, even if the header's token is invalid, and no
+ * CSRF token is needed since browsers never attach the header on their
+ * own. Cookies are only used for requests without an Authorization header.
+ */
+
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+const (
+	AccessTokenCookie  = "access_token"  // Cookie holding the access token (JWT)
+	RefreshTokenCookie = "refresh_token" // Cookie holding the refresh token
+	CSRFCookie         = "csrf_token"    // Cookie holding the CSRF token
+	CSRFHeader         = "X-CSRF-Token"  // Header the CSRF token must be repeated in
+	AuthModeHeader     = "X-Auth-Mode"   // "cookie" selects cookie authentication at sign-in
+)
+
+/**
+ * SecureCookies - Reports whether cookies should be marked Secure
+ *
+ * Lets handlers setting their own cookies follow AuthConfig.SecureCookies.
+ */
+func (a *Authenticator) SecureCookies() bool {
+	return a.secureCookies
+}
+
+/**
+ * WantsCookies - Reports whether a sign-in request asked for cookie mode
+ */
+func WantsCookies(r *http.Request) bool {
+	return r.Header.Get(AuthModeHeader) == "cookie"
+}
+
+/**
+ * SetAuthCookies - Stores a signed-in session's tokens in cookies
+ *
+ * A new CSRF token is issued each time, so it rotates with the refresh token.
+ *
+ * @param accessToken JWT access token
+ * @param accessTTL Access token lifetime
+ * @param refreshToken Opaque refresh token
+ * @param refreshTTL Refresh token lifetime
+ * @return The CSRF token to return to the client
+ */
+func (a *Authenticator) SetAuthCookies(w http.ResponseWriter, accessToken string, accessTTL time.Duration, refreshToken string, refreshTTL time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(b)
+
+	setCookie(w, AccessTokenCookie, accessToken, "/", accessTTL, true, a.secureCookies)
+	setCookie(w, RefreshTokenCookie, refreshToken, "/auth", refreshTTL, true, a.secureCookies)
+	setCookie(w, CSRFCookie, csrfToken, "/", refreshTTL, false, a.secureCookies)
+	return csrfToken, nil
+}
+
+/**
+ * ClearAuthCookies - Deletes the auth cookies set by SetAuthCookies
+ */
+func (a *Authenticator) ClearAuthCookies(w http.ResponseWriter) {
+	setCookie(w, AccessTokenCookie, "", "/", -1, true, a.secureCookies)
+	setCookie(w, RefreshTokenCookie, "", "/auth", -1, true, a.secureCookies)
+	setCookie(w, CSRFCookie, "", "/", -1, false, a.secureCookies)
+}
+
+/**
+ * ValidCSRF - Checks a cookie-authenticated request's CSRF token
+ *
+ * Safe methods (GET, HEAD, OPTIONS) need no token.
+ *
+ * @return true if the X-CSRF-Token header matches the csrf_token cookie
+ */
+func ValidCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
+
+/**
+ * CookieToken - Returns the access token cookie, if any
+ *
+ * Used by JWTMiddleware for requests without an Authorization header and
+ * by the WebSocket gateway, whose clients cannot set headers.
+ */
+func CookieToken(r *http.Request) string {
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// setCookie writes one auth cookie; a negative ttl deletes it.
+func setCookie(w http.ResponseWriter, name, value, path string, ttl time.Duration, httpOnly, secure bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: httpOnly,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if ttl < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
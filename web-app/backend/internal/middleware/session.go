@@ -0,0 +1,161 @@
+/**
+ * session.go - Server-side Session Revocation
+ *
+ * Every access token carries the ID of the session it was issued for in
+ * its "jti" claim. When AuthConfig.Sessions is set, ParseToken rejects
+ * tokens whose session was revoked or has expired, so killing a session
+ * also kills its access tokens.
+ *
+ * Caching:
+ * - Active sessions are remembered for the cache TTL to avoid a database
+ *   round trip per request; inactive results are never cached
+ * - ForgetSession drops an entry immediately, so revocations made by this
+ *   process apply at once and those made elsewhere within one TTL
+ * - Database errors fail closed: the token is rejected
+ * - At most maxCachedSessions entries are kept; when full, expired and
+ *   then arbitrary entries are evicted
+ */
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxCachedSessions bounds the cache; entries are evicted past this.
+const maxCachedSessions = 10000
+
+var (
+	// ErrSessionRevoked is returned by ParseToken for revoked or expired sessions.
+	ErrSessionRevoked = errors.New("session has been revoked")
+	// ErrNoSession is returned by ParseToken for tokens without a jti claim.
+	ErrNoSession = errors.New("token has no session")
+)
+
+/**
+ * SessionStore - Source of truth for whether a session is active
+ *
+ * Implemented by models.SessionService. TouchSession also records that
+ * the session was just used.
+ */
+type SessionStore interface {
+	TouchSession(ctx context.Context, id string) (bool, error)
+}
+
+/**
+ * sessionCache - Remembers recently confirmed active sessions
+ */
+type sessionCache struct {
+	store SessionStore
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	active map[string]time.Time // Session ID -> when to check again
+}
+
+/**
+ * newSessionCache - Creates an empty cache in front of store
+ *
+ * @param store Session lookups, usually models.SessionService
+ * @param ttl How long an active session is trusted without a lookup
+ */
+func newSessionCache(store SessionStore, ttl time.Duration) *sessionCache {
+	return &sessionCache{
+		store:  store,
+		ttl:    ttl,
+		active: make(map[string]time.Time),
+	}
+}
+
+/**
+ * ForgetSession - Drops a session from the cache after it was revoked
+ *
+ * @param id Session ID (the token's jti)
+ */
+func (a *Authenticator) ForgetSession(id string) {
+	if a.sessions != nil {
+		a.sessions.forget(id)
+	}
+}
+
+/**
+ * CheckSession - Verifies that the token's session is still active
+ *
+ * ParseToken calls this for every token; the gateway also calls it on
+ * heartbeats so long-lived connections notice revocations made elsewhere.
+ *
+ * @param ctx Bounds the database lookup
+ * @param id Session ID from the token's jti claim
+ * @return nil if active or no store is configured
+ */
+func (a *Authenticator) CheckSession(ctx context.Context, id string) error {
+	if a.sessions == nil {
+		return nil
+	}
+	if id == "" {
+		return ErrNoSession
+	}
+	return a.sessions.check(ctx, id)
+}
+
+func (c *sessionCache) check(ctx context.Context, id string) error {
+	now := time.Now()
+
+	c.mu.Lock()
+	until, ok := c.active[id]
+	c.mu.Unlock()
+	if ok && now.Before(until) {
+		return nil
+	}
+
+	active, err := c.store.TouchSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !active {
+		c.forget(id)
+		return ErrSessionRevoked
+	}
+
+	c.mu.Lock()
+	if len(c.active) >= maxCachedSessions {
+		c.evict(now)
+	}
+	c.active[id] = now.Add(c.ttl)
+	c.mu.Unlock()
+	return nil
+}
+
+// forget drops one entry so the next check asks the store again.
+func (c *sessionCache) forget(id string) {
+	c.mu.Lock()
+	delete(c.active, id)
+	c.mu.Unlock()
+}
+
+/**
+ * evict - Makes room in a full cache
+ *
+ * Drops expired entries first. If every entry is still live, arbitrary
+ * ones are dropped until a tenth of the cache is free, so a burst of
+ * sessions does not sweep the whole map on every request. Evicted
+ * sessions are simply looked up again. Called with c.mu held.
+ *
+ * @param now Current time
+ */
+func (c *sessionCache) evict(now time.Time) {
+	for key, expiry := range c.active {
+		if now.After(expiry) {
+			delete(c.active, key)
+		}
+	}
+	for key := range c.active {
+		if len(c.active) < maxCachedSessions*9/10 {
+			break
+		}
+		delete(c.active, key)
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is a SessionStore that counts lookups per session.
+type fakeStore struct {
+	mu      sync.Mutex
+	active  map[string]bool
+	err     error
+	lookups map[string]int
+}
+
+func newFakeStore(active ...string) *fakeStore {
+	s := &fakeStore{active: make(map[string]bool), lookups: make(map[string]int)}
+	for _, id := range active {
+		s.active[id] = true
+	}
+	return s
+}
+
+func (s *fakeStore) TouchSession(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups[id]++
+	return s.active[id], s.err
+}
+
+func (s *fakeStore) count(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups[id]
+}
+
+func (s *fakeStore) set(id string, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[id] = active
+}
+
+func newTestAuthenticator(store SessionStore) *Authenticator {
+	return NewAuthenticator(AuthConfig{Sessions: store, SessionTTL: time.Minute})
+}
+
+// check calls CheckSession and fails the test on an unexpected result.
+func check(t *testing.T, a *Authenticator, id string, want error) {
+	t.Helper()
+	if err := a.CheckSession(context.Background(), id); !errors.Is(err, want) {
+		t.Fatalf("CheckSession(%q) = %v, want %v", id, err, want)
+	}
+}
+
+func TestSessionCacheHitWithinTTL(t *testing.T) {
+	store := newFakeStore("s1")
+	a := newTestAuthenticator(store)
+
+	for i := 0; i < 3; i++ {
+		check(t, a, "s1", nil)
+	}
+	if got := store.count("s1"); got != 1 {
+		t.Fatalf("store was asked %d times, want 1", got)
+	}
+}
+
+func TestSessionCacheExpiry(t *testing.T) {
+	store := newFakeStore("s1")
+	a := newTestAuthenticator(store)
+	check(t, a, "s1", nil)
+
+	// Revoked elsewhere: the cached entry hides it until the TTL runs out
+	store.set("s1", false)
+	check(t, a, "s1", nil)
+
+	a.sessions.mu.Lock()
+	a.sessions.active["s1"] = time.Now().Add(-time.Second)
+	a.sessions.mu.Unlock()
+
+	check(t, a, "s1", ErrSessionRevoked)
+	if got := store.count("s1"); got != 2 {
+		t.Fatalf("store was asked %d times, want 2", got)
+	}
+}
+
+func TestSessionCacheSkipsInactive(t *testing.T) {
+	store := newFakeStore()
+	a := newTestAuthenticator(store)
+
+	check(t, a, "gone", ErrSessionRevoked)
+	check(t, a, "gone", ErrSessionRevoked)
+	if got := store.count("gone"); got != 2 {
+		t.Fatalf("store was asked %d times, want 2 (inactive results are not cached)", got)
+	}
+
+	// A session that comes back is trusted again
+	store.set("gone", true)
+	check(t, a, "gone", nil)
+}
+
+func TestSessionCacheFailsClosed(t *testing.T) {
+	store := newFakeStore("s1")
+	store.err = errors.New("database is down")
+	a := newTestAuthenticator(store)
+
+	check(t, a, "s1", store.err)
+
+	// The error is not cached either way
+	store.err = nil
+	check(t, a, "s1", nil)
+	if got := store.count("s1"); got != 2 {
+		t.Fatalf("store was asked %d times, want 2", got)
+	}
+}
+
+func TestForgetSession(t *testing.T) {
+	store := newFakeStore("s1", "s2")
+	a := newTestAuthenticator(store)
+	check(t, a, "s1", nil)
+	check(t, a, "s2", nil)
+
+	// Revoked by this process: the next check must not use the cache
+	store.set("s1", false)
+	a.ForgetSession("s1")
+	check(t, a, "s1", ErrSessionRevoked)
+	if got := store.count("s1"); got != 2 {
+		t.Fatalf("store was asked %d times for s1, want 2", got)
+	}
+
+	// Other sessions stay cached
+	check(t, a, "s2", nil)
+	if got := store.count("s2"); got != 1 {
+		t.Fatalf("store was asked %d times for s2, want 1", got)
+	}
+}
+
+func TestSessionCacheBound(t *testing.T) {
+	tests := []struct {
+		name    string
+		expired int // Entries already past their TTL when the cache fills
+		maxLen  int
+	}{
+		{"expired entries are dropped first", maxCachedSessions / 2, maxCachedSessions/2 + 1},
+		{"live entries are dropped when nothing expired", 0, maxCachedSessions * 9 / 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore("new")
+			a := newTestAuthenticator(store)
+
+			now := time.Now()
+			for i := 0; i < maxCachedSessions; i++ {
+				expiry := now.Add(time.Minute)
+				if i < tt.expired {
+					expiry = now.Add(-time.Minute)
+				}
+				a.sessions.active["s"+strconv.Itoa(i)] = expiry
+			}
+
+			check(t, a, "new", nil)
+
+			a.sessions.mu.Lock()
+			defer a.sessions.mu.Unlock()
+			if got := len(a.sessions.active); got > tt.maxLen {
+				t.Fatalf("cache holds %d entries, want at most %d", got, tt.maxLen)
+			}
+			if _, ok := a.sessions.active["new"]; !ok {
+				t.Fatal("the session that triggered eviction was not cached")
+			}
+			for key, expiry := range a.sessions.active {
+				if now.After(expiry) {
+					t.Fatalf("expired entry %s survived eviction", key)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSessionWithoutStore(t *testing.T) {
+	a := NewAuthenticator(AuthConfig{})
+	check(t, a, "", nil)
+	check(t, a, "anything", nil)
+	a.ForgetSession("anything")
+
+	// With a store, tokens without a session ID are rejected outright
+	store := newFakeStore()
+	check(t, newTestAuthenticator(store), "", ErrNoSession)
+	if got := store.count(""); got != 0 {
+		t.Fatalf("store was asked %d times for an empty ID, want 0", got)
+	}
+}
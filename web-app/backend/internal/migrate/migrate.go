@@ -0,0 +1,329 @@
+/**
+ * migrate.go - Versioned Schema Migration Runner
+ *
+ * Applies the SQL migrations embedded in the migrations package and
+ * records each applied version in the schema_migrations table.
+ *
+ * Guarantees:
+ * - Each migration runs in its own transaction together with its
+ *   schema_migrations row, so a failed migration leaves no trace
+ * - A PostgreSQL advisory lock is held while migrating, so server
+ *   instances starting at the same time apply each migration once; the
+ *   others wait and then find nothing left to do
+ * - Versions recorded in the database but missing from the binary (an
+ *   older binary against a newer schema) are reported, never rolled back
+ *
+ * Usage:
+ * m, err := migrate.New(db, migrations.FS)
+ * applied, err := m.Up(ctx)
+ */
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockID identifies the advisory lock held while migrating ("migrate" in hex).
+const lockID = 0x6d696772617465
+
+// fileName matches migration files, e.g. "0004_add_roles.up.sql".
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+/**
+ * Migration - One schema version
+ */
+type Migration struct {
+	Version int    // Position in the sequence, from the file name prefix
+	Name    string // Descriptive part of the file name
+	Up      string // SQL applying the change
+	Down    string // SQL reverting it
+}
+
+/**
+ * Status - A migration and whether it is applied
+ */
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"` // nil if pending
+	Missing   bool       `json:"missing"`    // Applied but not known to this binary
+}
+
+/**
+ * Migrator - Applies and reverts migrations against one database
+ */
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration // Sorted by version
+}
+
+/**
+ * New - Loads the migrations in fsys
+ *
+ * Every version needs exactly one up and one down file with the same
+ * name, and versions must be unique (0004_x and 04_x are the same version).
+ *
+ * @param db Database to migrate
+ * @param fsys Directory of migration files (normally migrations.FS)
+ * @return Configured Migrator, or an error describing the bad files
+ */
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	seen := make(map[string]string) // "<version>.<up|down>" -> file name
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		key := strconv.Itoa(version) + "." + match[3]
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("migration %d has two %s files: %s and %s", version, match[3], other, entry.Name())
+		}
+		seen[key] = entry.Name()
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrator := &Migrator{db: db}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrator.migrations = append(migrator.migrations, *m)
+	}
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+	return migrator, nil
+}
+
+/**
+ * Latest - Returns the highest version known to this binary (0 if none)
+ */
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+/**
+ * Up - Applies every pending migration in version order
+ *
+ * @return The migrations that were applied, possibly before an error
+ */
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := run(ctx, conn, migration, migration.Up, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+/**
+ * Down - Reverts the most recently applied migrations
+ *
+ * @param steps How many migrations to revert
+ * @return The migrations that were reverted, newest first
+ */
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := run(ctx, conn, migration, migration.Down, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+/**
+ * Status - Lists every migration and whether it is applied
+ *
+ * Includes versions recorded in the database that this binary does not
+ * know (Missing). Read-only; does not take the lock.
+ */
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	done := make(map[int]time.Time)
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		if done, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := done[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, appliedAt := range done {
+		statuses = append(statuses, Status{Version: version, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+/**
+ * Pending - Counts the migrations this binary would apply
+ */
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+/**
+ * locked - Runs fn on one connection while holding the migration lock
+ *
+ * Advisory locks belong to a database session, so the lock and every
+ * migration share a single connection.
+ */
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	// Unlock with a fresh context so a cancelled ctx still releases the lock
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable creates schema_migrations if it does not exist yet.
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// appliedVersions returns when each recorded version was applied.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// run executes one direction of a migration and records the result in
+// the same transaction.
+func run(ctx context.Context, conn *sql.Conn, migration Migration, script string, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments lib/pq sends the script as one simple query, so
+	// files can hold several statements
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+			migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,118 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/user/web-app/migrations"
+)
+
+// files builds an in-memory migrations directory; every file gets a
+// non-empty body.
+func files(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestNewOrdersByVersion(t *testing.T) {
+	m, err := New(nil, files(
+		"10_tenth.up.sql", "10_tenth.down.sql",
+		"2_second.up.sql", "2_second.down.sql",
+		"0001_first.up.sql", "0001_first.down.sql",
+		"README.md", "0003_notes.sql",
+	))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var got []int
+	for _, migration := range m.migrations {
+		got = append(got, migration.Version)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 10 {
+		t.Fatalf("versions = %v, want [1 2 10]", got)
+	}
+	if m.Latest() != 10 {
+		t.Fatalf("Latest = %d, want 10", m.Latest())
+	}
+
+	first := m.migrations[0]
+	if first.Name != "first" || first.Up != "-- 0001_first.up.sql" || first.Down != "-- 0001_first.down.sql" {
+		t.Fatalf("first migration = %+v", first)
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	m, err := New(nil, files())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if m.Latest() != 0 {
+		t.Fatalf("Latest = %d, want 0", m.Latest())
+	}
+}
+
+func TestNewRejectsMalformedSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{
+			name:  "missing down file",
+			fsys:  files("0001_init.up.sql", "0001_init.down.sql", "0002_roles.up.sql"),
+			error: "migration 2_roles needs both an up and a down file",
+		},
+		{
+			name:  "missing up file",
+			fsys:  files("0001_init.down.sql"),
+			error: "migration 1_init needs both an up and a down file",
+		},
+		{
+			name:  "empty down file",
+			fsys:  fstest.MapFS{"0001_init.up.sql": {Data: []byte("SELECT 1")}, "0001_init.down.sql": {}},
+			error: "needs both an up and a down file",
+		},
+		{
+			name:  "up and down names differ",
+			fsys:  files("0001_init.up.sql", "0001_initial.down.sql"),
+			error: "migration 1 has two names",
+		},
+		{
+			name:  "duplicate version with different names",
+			fsys:  files("0001_init.up.sql", "0001_init.down.sql", "0001_roles.up.sql", "0001_roles.down.sql"),
+			error: "migration 1 has two down files",
+		},
+		{
+			name:  "duplicate version with different padding",
+			fsys:  files("0001_init.up.sql", "0001_init.down.sql", "1_init.up.sql", "1_init.down.sql"),
+			error: "migration 1 has two down files",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(nil, tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Fatalf("New error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrations fails the build when a malformed migration is
+// added, rather than the server refusing to start.
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil, migrations.FS)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i, migration := range m.migrations {
+		if migration.Version != i+1 {
+			t.Fatalf("migration %d_%s is out of sequence, want version %d", migration.Version, migration.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// AuthCodeTTL is how long a sign-in code can be exchanged for tokens. The
+// frontend redeems it immediately after the redirect.
+const AuthCodeTTL = time.Minute
+
+// ErrInvalidAuthCode is returned for unknown, expired or already used codes.
+var ErrInvalidAuthCode = errors.New("invalid authorization code")
+
+type AuthCodeService struct {
+	db *sql.DB
+}
+
+func NewAuthCodeService(db *sql.DB) *AuthCodeService {
+	return &AuthCodeService{db: db}
+}
+
+// CreateAuthCode issues a single-use code that signs userID in.
+func (s *AuthCodeService) CreateAuthCode(ctx context.Context, userID int) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Expired codes are never redeemed; clear them out as new ones arrive
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM auth_codes WHERE expires_at <= $1`, time.Now()); err != nil {
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO auth_codes (code_hash, user_id, expires_at) VALUES ($1, $2, $3)`,
+		hashToken(code), userID, time.Now().Add(AuthCodeTTL))
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemAuthCode consumes a code and returns the user it signs in.
+func (s *AuthCodeService) RedeemAuthCode(ctx context.Context, code string) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx, `DELETE FROM auth_codes WHERE code_hash = $1 AND expires_at > $2
+						  RETURNING user_id`, hashToken(code), time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidAuthCode
+	}
+	return userID, err
+}
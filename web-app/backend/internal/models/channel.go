@@ -0,0 +1,196 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ChannelTypeText     = "text"
+	ChannelTypeVoice    = "voice"
+	ChannelTypeCategory = "category"
+	ChannelTypeDM       = "dm"       // 1:1 direct message, no server
+	ChannelTypeGroupDM  = "group_dm" // Small group conversation, no server
+)
+
+// ErrChannelNotInServer is returned when a reorder references a channel
+// that belongs to a different server (or does not exist).
+var ErrChannelNotInServer = errors.New("channel does not belong to server")
+
+type Channel struct {
+	ID        int       `json:"id" db:"id"`
+	ServerID  int       `json:"server_id,omitempty" db:"server_id"` // 0 for DMs
+	Name      string    `json:"name" db:"name"`
+	Type      string    `json:"type" db:"type"`
+	Position  int       `json:"position" db:"position"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ChannelPosition struct {
+	ID       int `json:"id"`
+	Position int `json:"position"`
+}
+
+type ChannelService struct {
+	db *sql.DB
+}
+
+func NewChannelService(db *sql.DB) *ChannelService {
+	return &ChannelService{db: db}
+}
+
+// IsValidChannelType reports whether channelType can be created in a server.
+func IsValidChannelType(channelType string) bool {
+	switch channelType {
+	case ChannelTypeText, ChannelTypeVoice, ChannelTypeCategory:
+		return true
+	}
+	return false
+}
+
+// IsPrivate reports whether the channel is a DM or group DM outside any server.
+func (c *Channel) IsPrivate() bool {
+	return c.Type == ChannelTypeDM || c.Type == ChannelTypeGroupDM
+}
+
+// IsTextBased reports whether messages can be sent to the channel.
+func (c *Channel) IsTextBased() bool {
+	return c.Type == ChannelTypeText || c.IsPrivate()
+}
+
+// CreateChannel appends the new channel after the server's existing channels.
+func (s *ChannelService) CreateChannel(ctx context.Context, serverID int, name, channelType string) (*Channel, error) {
+	channel := &Channel{}
+	query := `INSERT INTO channels (server_id, name, type, position)
+			  VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position) + 1, 0) FROM channels WHERE server_id = $1))
+			  RETURNING id, server_id, name, type, position, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, serverID, name, channelType).Scan(
+		&channel.ID, &channel.ServerID, &channel.Name, &channel.Type,
+		&channel.Position, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+func (s *ChannelService) GetChannelByID(ctx context.Context, id int) (*Channel, error) {
+	channel := &Channel{}
+	query := `SELECT id, COALESCE(server_id, 0), name, type, position, created_at, updated_at
+			  FROM channels WHERE id = $1`
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&channel.ID, &channel.ServerID, &channel.Name, &channel.Type,
+		&channel.Position, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+func (s *ChannelService) GetChannelsByServer(ctx context.Context, serverID int) ([]*Channel, error) {
+	query := `SELECT id, server_id, name, type, position, created_at, updated_at
+			  FROM channels WHERE server_id = $1
+			  ORDER BY position, id`
+
+	rows, err := s.db.QueryContext(ctx, query, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []*Channel{}
+	for rows.Next() {
+		channel := &Channel{}
+		if err := rows.Scan(
+			&channel.ID, &channel.ServerID, &channel.Name, &channel.Type,
+			&channel.Position, &channel.CreatedAt, &channel.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels, rows.Err()
+}
+
+func (s *ChannelService) RenameChannel(ctx context.Context, id int, name string) (*Channel, error) {
+	channel := &Channel{}
+	query := `UPDATE channels SET name = $2, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1
+			  RETURNING id, server_id, name, type, position, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, id, name).Scan(
+		&channel.ID, &channel.ServerID, &channel.Name, &channel.Type,
+		&channel.Position, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+func (s *ChannelService) DeleteChannel(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM channels WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ReorderChannels applies all position updates in a single transaction.
+// If any channel is not part of the server nothing is changed.
+func (s *ChannelService) ReorderChannels(ctx context.Context, serverID int, positions []ChannelPosition) ([]*Channel, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE channels SET position = $3, updated_at = CURRENT_TIMESTAMP
+							 WHERE id = $1 AND server_id = $2`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, p := range positions {
+		result, err := stmt.ExecContext(ctx, p.ID, serverID, p.Position)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, ErrChannelNotInServer
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetChannelsByServer(ctx, serverID)
+}
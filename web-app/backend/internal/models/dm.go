@@ -0,0 +1,347 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// MaxGroupDMRecipients caps the number of participants in a group DM,
+// including its owner.
+const MaxGroupDMRecipients = 10
+
+var (
+	// ErrDMFull is returned when adding a recipient to a full group DM.
+	ErrDMFull = errors.New("group DM is full")
+	// ErrAlreadyRecipient is returned when adding an existing participant.
+	ErrAlreadyRecipient = errors.New("user is already in this conversation")
+	// ErrNotGroupDM is returned when changing participants of a 1:1 DM.
+	ErrNotGroupDM = errors.New("channel is not a group DM")
+)
+
+// Recipient is the public view of a DM participant.
+type Recipient struct {
+	ID          int     `json:"id" db:"id"`
+	Username    string  `json:"username" db:"username"`
+	DisplayName *string `json:"display_name" db:"display_name"`
+	AvatarURL   *string `json:"avatar_url" db:"avatar_url"`
+}
+
+// DMChannel is a DM or group DM together with its participants.
+type DMChannel struct {
+	Channel
+	OwnerID    *int         `json:"owner_id" db:"owner_id"`
+	Recipients []*Recipient `json:"recipients"`
+}
+
+type DMService struct {
+	db *sql.DB
+}
+
+func NewDMService(db *sql.DB) *DMService {
+	return &DMService{db: db}
+}
+
+const dmColumns = `c.id, c.name, c.type, c.position, c.created_at, c.updated_at, c.owner_id`
+
+func scanDM(row interface{ Scan(...interface{}) error }) (*DMChannel, error) {
+	dm := &DMChannel{}
+	err := row.Scan(
+		&dm.ID, &dm.Name, &dm.Type, &dm.Position,
+		&dm.CreatedAt, &dm.UpdatedAt, &dm.OwnerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return dm, nil
+}
+
+// dmKey identifies the 1:1 DM between two users regardless of order.
+func dmKey(a, b int) string {
+	return fmt.Sprintf("%d:%d", min(a, b), max(a, b))
+}
+
+// GetOrCreateDM returns the 1:1 DM between two users, creating it if it
+// does not exist yet. created reports whether a new channel was made.
+// Returns sql.ErrNoRows if recipientID is not a user.
+func (s *DMService) GetOrCreateDM(ctx context.Context, userID, recipientID int) (dm *DMChannel, created bool, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var channelID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO channels (name, type, dm_key)
+					   VALUES ('', $1, $2)
+					   ON CONFLICT (dm_key) DO NOTHING
+					   RETURNING id`, ChannelTypeDM, dmKey(userID, recipientID)).Scan(&channelID)
+	if err == sql.ErrNoRows {
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM channels WHERE dm_key = $1`, dmKey(userID, recipientID)).Scan(&channelID); err != nil {
+			return nil, false, err
+		}
+	} else if err != nil {
+		return nil, false, err
+	} else {
+		created = true
+		if err := insertRecipients(ctx, tx, channelID, []int{userID, recipientID}); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	dm, err = s.GetDM(ctx, channelID)
+	return dm, created, err
+}
+
+// CreateGroupDM creates a group DM owned by ownerID with the given other
+// participants. Returns sql.ErrNoRows if any recipient is not a user.
+func (s *DMService) CreateGroupDM(ctx context.Context, ownerID int, recipientIDs []int) (*DMChannel, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var channelID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO channels (name, type, owner_id)
+					   VALUES ('', $1, $2)
+					   RETURNING id`, ChannelTypeGroupDM, ownerID).Scan(&channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertRecipients(ctx, tx, channelID, append([]int{ownerID}, recipientIDs...)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetDM(ctx, channelID)
+}
+
+// GetDM returns a DM channel with its participants.
+func (s *DMService) GetDM(ctx context.Context, channelID int) (*DMChannel, error) {
+	query := `SELECT ` + dmColumns + ` FROM channels c
+			  WHERE c.id = $1 AND c.type IN ($2, $3)`
+
+	dm, err := scanDM(s.db.QueryRowContext(ctx, query, channelID, ChannelTypeDM, ChannelTypeGroupDM))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadRecipients(ctx, []*DMChannel{dm}); err != nil {
+		return nil, err
+	}
+	return dm, nil
+}
+
+// ListDMs returns the user's DMs, most recently active first.
+func (s *DMService) ListDMs(ctx context.Context, userID int) ([]*DMChannel, error) {
+	query := `SELECT ` + dmColumns + `
+			  FROM channels c
+			  JOIN channel_recipients cr ON cr.channel_id = c.id
+			  WHERE cr.user_id = $1
+			  ORDER BY (SELECT MAX(m.id) FROM messages m WHERE m.channel_id = c.id) DESC NULLS LAST, c.id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dms := []*DMChannel{}
+	for rows.Next() {
+		dm, err := scanDM(rows)
+		if err != nil {
+			return nil, err
+		}
+		dms = append(dms, dm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadRecipients(ctx, dms); err != nil {
+		return nil, err
+	}
+	return dms, nil
+}
+
+// GetRecipientIDs returns the user IDs of a DM's participants.
+func (s *DMService) GetRecipientIDs(ctx context.Context, channelID int) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id FROM channel_recipients WHERE channel_id = $1 ORDER BY user_id`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *DMService) IsRecipient(ctx context.Context, channelID, userID int) (bool, error) {
+	var ok bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+		channelID, userID).Scan(&ok)
+	return ok, err
+}
+
+// AddRecipient adds a participant to a group DM, enforcing
+// MaxGroupDMRecipients. Returns sql.ErrNoRows if userID is not a user.
+func (s *DMService) AddRecipient(ctx context.Context, channelID, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the channel so concurrent adds cannot exceed the limit
+	var channelType string
+	if err := tx.QueryRowContext(ctx, `SELECT type FROM channels WHERE id = $1 FOR UPDATE`, channelID).Scan(&channelType); err != nil {
+		return err
+	}
+	if channelType != ChannelTypeGroupDM {
+		return ErrNotGroupDM
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM channel_recipients WHERE channel_id = $1`, channelID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxGroupDMRecipients {
+		return ErrDMFull
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO channel_recipients (channel_id, user_id) VALUES ($1, $2)
+							ON CONFLICT DO NOTHING`, channelID, userID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAlreadyRecipient
+	}
+
+	return tx.Commit()
+}
+
+// RemoveRecipient removes a participant from a group DM. If the owner
+// leaves, ownership passes to the longest-standing remaining participant;
+// when nobody is left the channel and its messages are deleted.
+func (s *DMService) RemoveRecipient(ctx context.Context, channelID, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var channelType string
+	var ownerID sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT type, owner_id FROM channels WHERE id = $1 FOR UPDATE`, channelID).Scan(&channelType, &ownerID); err != nil {
+		return err
+	}
+	if channelType != ChannelTypeGroupDM {
+		return ErrNotGroupDM
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM channel_recipients WHERE channel_id = $1 AND user_id = $2`, channelID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if ownerID.Valid && int(ownerID.Int64) == userID {
+		_, err = tx.ExecContext(ctx, `UPDATE channels SET owner_id = (
+							  SELECT user_id FROM channel_recipients WHERE channel_id = $1
+							  ORDER BY added_at, user_id LIMIT 1
+						  ), updated_at = CURRENT_TIMESTAMP
+						  WHERE id = $1`, channelID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM channels c WHERE c.id = $1
+					  AND NOT EXISTS (SELECT 1 FROM channel_recipients cr WHERE cr.channel_id = c.id)`, channelID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertRecipients adds participants inside tx, mapping a missing user to
+// sql.ErrNoRows.
+func insertRecipients(ctx context.Context, tx *sql.Tx, channelID int, userIDs []int) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO channel_recipients (channel_id, user_id)
+					   SELECT $1, unnest($2::int[])
+					   ON CONFLICT DO NOTHING`, channelID, pq.Array(userIDs))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// loadRecipients fills in Recipients for each DM with a single query.
+func (s *DMService) loadRecipients(ctx context.Context, dms []*DMChannel) error {
+	if len(dms) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*DMChannel, len(dms))
+	ids := make([]int, len(dms))
+	for i, dm := range dms {
+		dm.Recipients = []*Recipient{}
+		byID[dm.ID] = dm
+		ids[i] = dm.ID
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT cr.channel_id, u.id, u.username, u.display_name, u.avatar_url
+							 FROM channel_recipients cr JOIN users u ON u.id = cr.user_id
+							 WHERE cr.channel_id = ANY($1)
+							 ORDER BY cr.added_at, u.id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channelID int
+		recipient := &Recipient{}
+		if err := rows.Scan(&channelID, &recipient.ID, &recipient.Username, &recipient.DisplayName, &recipient.AvatarURL); err != nil {
+			return err
+		}
+		byID[channelID].Recipients = append(byID[channelID].Recipients, recipient)
+	}
+
+	return rows.Err()
+}
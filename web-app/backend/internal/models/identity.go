@@ -0,0 +1,219 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PendingLinkTTL is how long a link request or email-match confirmation
+// stays valid.
+const PendingLinkTTL = 15 * time.Minute
+
+var (
+	// ErrIdentityLinked is returned when a provider account is already
+	// linked to a user.
+	ErrIdentityLinked = errors.New("identity is already linked to an account")
+	// ErrLastLoginMethod is returned when unlinking would leave a user with
+	// no way to sign in.
+	ErrLastLoginMethod = errors.New("cannot remove the last login method")
+	// ErrInvalidLinkToken is returned for unknown, expired or foreign link
+	// tokens.
+	ErrInvalidLinkToken = errors.New("invalid link token")
+)
+
+// Identity is a login provider account linked to a user.
+type Identity struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"-" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     *string   `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type IdentityService struct {
+	db *sql.DB
+}
+
+func NewIdentityService(db *sql.DB) *IdentityService {
+	return &IdentityService{db: db}
+}
+
+const identityColumns = `id, user_id, provider, subject, email, created_at`
+
+func scanIdentity(row interface{ Scan(...interface{}) error }) (*Identity, error) {
+	identity := &Identity{}
+	err := row.Scan(
+		&identity.ID, &identity.UserID, &identity.Provider,
+		&identity.Subject, &identity.Email, &identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// ListIdentities returns the identities linked to userID, oldest first.
+func (s *IdentityService) ListIdentities(ctx context.Context, userID int) ([]*Identity, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+identityColumns+` FROM user_identities
+							 WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*Identity{}
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// LinkIdentity links a provider account to userID. Returns
+// ErrIdentityLinked if it is already linked to any user.
+func (s *IdentityService) LinkIdentity(ctx context.Context, userID int, provider, subject, email string) (*Identity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	identity, err := insertIdentity(ctx, tx, userID, provider, subject, email)
+	if err != nil {
+		return nil, err
+	}
+	return identity, tx.Commit()
+}
+
+// UnlinkIdentity removes one of userID's identities. The user row is locked
+// so concurrent unlinks cannot together remove every login method; a user
+// without a password must keep at least one identity.
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasPassword bool
+	err = tx.QueryRowContext(ctx, `SELECT password_hash IS NOT NULL FROM users
+					   WHERE id = $1 FOR UPDATE`, userID).Scan(&hasPassword)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	var found bool
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(BOOL_OR(id = $2), false) FROM user_identities
+					   WHERE user_id = $1`, userID, id).Scan(&count, &found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+	if count == 1 && !hasPassword {
+		return ErrLastLoginMethod
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateLinkRequest records that userID wants to link an account from
+// provider. The returned token is redeemed by TakeLinkRequest when the
+// provider redirects back.
+func (s *IdentityService) CreateLinkRequest(ctx context.Context, userID int, provider string) (string, error) {
+	return s.createPendingLink(ctx, userID, provider, nil, nil)
+}
+
+// TakeLinkRequest consumes a link request token for provider and returns
+// the user who made it.
+func (s *IdentityService) TakeLinkRequest(ctx context.Context, token, provider string) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx, `DELETE FROM pending_identity_links
+						  WHERE token_hash = $1 AND provider = $2 AND subject IS NULL AND expires_at > $3
+						  RETURNING user_id`, hashToken(token), provider, time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidLinkToken
+	}
+	return userID, err
+}
+
+// CreatePendingLink records a provider account whose verified email
+// matches userID's. It is only linked once the user confirms with
+// ConfirmPendingLink while signed in to their existing account.
+func (s *IdentityService) CreatePendingLink(ctx context.Context, userID int, provider, subject, email string) (string, error) {
+	return s.createPendingLink(ctx, userID, provider, &subject, &email)
+}
+
+// ConfirmPendingLink links the provider account recorded under token to
+// userID. The token must have been created for the same user.
+func (s *IdentityService) ConfirmPendingLink(ctx context.Context, userID int, token string) (*Identity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var provider, subject string
+	var email sql.NullString
+	err = tx.QueryRowContext(ctx, `DELETE FROM pending_identity_links
+					   WHERE token_hash = $1 AND user_id = $2 AND subject IS NOT NULL AND expires_at > $3
+					   RETURNING provider, subject, email`, hashToken(token), userID, time.Now()).
+		Scan(&provider, &subject, &email)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidLinkToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := insertIdentity(ctx, tx, userID, provider, subject, email.String)
+	if err != nil {
+		return nil, err
+	}
+	return identity, tx.Commit()
+}
+
+func (s *IdentityService) createPendingLink(ctx context.Context, userID int, provider string, subject, email *string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Expired rows are never redeemed; clear them out as new ones arrive
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM pending_identity_links WHERE expires_at <= $1`, time.Now()); err != nil {
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO pending_identity_links (token_hash, user_id, provider, subject, email, expires_at)
+						VALUES ($1, $2, $3, $4, $5, $6)`,
+		hashToken(token), userID, provider, subject, email, time.Now().Add(PendingLinkTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func insertIdentity(ctx context.Context, tx *sql.Tx, userID int, provider, subject, email string) (*Identity, error) {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email)
+			  VALUES ($1, $2, $3, NULLIF($4, ''))
+			  RETURNING ` + identityColumns
+
+	identity, err := scanIdentity(tx.QueryRowContext(ctx, query, userID, provider, subject, email))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrIdentityLinked
+	}
+	return identity, err
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// ErrInviteInvalid is returned when an invite does not exist, has expired
+// or has reached its maximum number of uses.
+var ErrInviteInvalid = errors.New("invite is invalid or has expired")
+
+type Invite struct {
+	Code      string     `json:"code" db:"code"`
+	ServerID  int        `json:"server_id" db:"server_id"`
+	InviterID *int       `json:"inviter_id" db:"inviter_id"`
+	MaxUses   *int       `json:"max_uses" db:"max_uses"`
+	Uses      int        `json:"uses" db:"uses"`
+	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// InvitePreview is the public view of an invite shown before joining.
+type InvitePreview struct {
+	Code        string     `json:"code"`
+	ServerID    int        `json:"server_id"`
+	ServerName  string     `json:"server_name"`
+	IconURL     *string    `json:"icon_url"`
+	MemberCount int        `json:"member_count"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type InviteService struct {
+	db *sql.DB
+}
+
+func NewInviteService(db *sql.DB) *InviteService {
+	return &InviteService{db: db}
+}
+
+const inviteColumns = `code, server_id, inviter_id, max_uses, uses, expires_at, created_at`
+
+// usableInvite restricts a query on invites to ones that can still be used.
+const usableInvite = `(expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+			  AND (max_uses IS NULL OR uses < max_uses)`
+
+func scanInvite(row interface{ Scan(...interface{}) error }) (*Invite, error) {
+	invite := &Invite{}
+	err := row.Scan(
+		&invite.Code, &invite.ServerID, &invite.InviterID, &invite.MaxUses,
+		&invite.Uses, &invite.ExpiresAt, &invite.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// CreateInvite generates a random code, retrying on the rare collision.
+func (s *InviteService) CreateInvite(ctx context.Context, serverID, inviterID int, maxUses *int, expiresAt *time.Time) (*Invite, error) {
+	query := `INSERT INTO invites (code, server_id, inviter_id, max_uses, expires_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING ` + inviteColumns
+
+	for attempt := 0; ; attempt++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			return nil, err
+		}
+
+		invite, err := scanInvite(s.db.QueryRowContext(ctx, query, code, serverID, inviterID, maxUses, expiresAt))
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && attempt < 3 {
+			continue
+		}
+		return invite, err
+	}
+}
+
+func (s *InviteService) GetInvitePreview(ctx context.Context, code string) (*InvitePreview, error) {
+	preview := &InvitePreview{}
+	query := `SELECT i.code, s.id, s.name, s.icon_url,
+					 (SELECT COUNT(*) FROM server_members sm WHERE sm.server_id = s.id),
+					 i.expires_at
+			  FROM invites i JOIN servers s ON s.id = i.server_id
+			  WHERE i.code = $1 AND ` + usableInvite
+
+	err := s.db.QueryRowContext(ctx, query, code).Scan(
+		&preview.Code, &preview.ServerID, &preview.ServerName, &preview.IconURL,
+		&preview.MemberCount, &preview.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+func (s *InviteService) ListInvites(ctx context.Context, serverID int) ([]*Invite, error) {
+	query := `SELECT ` + inviteColumns + `
+			  FROM invites WHERE server_id = $1
+			  ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []*Invite{}
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func (s *InviteService) RevokeInvite(ctx context.Context, serverID int, code string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM invites WHERE server_id = $1 AND code = $2`, serverID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// AcceptInvite consumes one use of the invite and adds the user to the
+// server in a single transaction. Existing members get ErrAlreadyMember
+// and do not consume a use.
+func (s *InviteService) AcceptInvite(ctx context.Context, code string, userID int) (*Member, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var serverID int
+	err = tx.QueryRowContext(ctx, `UPDATE invites SET uses = uses + 1
+					   WHERE code = $1 AND `+usableInvite+`
+					   RETURNING server_id`, code).Scan(&serverID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `WITH sm AS (
+				  INSERT INTO server_members (server_id, user_id)
+				  VALUES ($1, $2)
+				  ON CONFLICT (server_id, user_id) DO NOTHING
+				  RETURNING server_id, user_id, joined_at
+			  )
+			  ` + memberSelect + `
+			  FROM sm JOIN users u ON u.id = sm.user_id`
+
+	member, err := scanMember(tx.QueryRowContext(ctx, query, serverID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func generateInviteCode() (string, error) {
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	code := make([]byte, inviteCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrAlreadyMember is returned by AddMember when the user already belongs
+// to the server.
+var ErrAlreadyMember = errors.New("user is already a member of this server")
+
+type Member struct {
+	ServerID    int       `json:"server_id" db:"server_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	Username    string    `json:"username" db:"username"`
+	DisplayName *string   `json:"display_name" db:"display_name"`
+	AvatarURL   *string   `json:"avatar_url" db:"avatar_url"`
+	Roles       []int64   `json:"roles"`
+	JoinedAt    time.Time `json:"joined_at" db:"joined_at"`
+}
+
+type MemberService struct {
+	db *sql.DB
+}
+
+func NewMemberService(db *sql.DB) *MemberService {
+	return &MemberService{db: db}
+}
+
+// memberSelect selects a member row aliased as sm joined with users as u,
+// including the IDs of the member's assigned roles.
+const memberSelect = `SELECT sm.server_id, sm.user_id, u.username, u.display_name, u.avatar_url,
+				 ARRAY(SELECT mr.role_id FROM member_roles mr
+					   WHERE mr.server_id = sm.server_id AND mr.user_id = sm.user_id
+					   ORDER BY mr.role_id),
+				 sm.joined_at`
+
+func (s *MemberService) AddMember(ctx context.Context, serverID, userID int) (*Member, error) {
+	query := `WITH sm AS (
+				  INSERT INTO server_members (server_id, user_id)
+				  VALUES ($1, $2)
+				  RETURNING server_id, user_id, joined_at
+			  )
+			  ` + memberSelect + `
+			  FROM sm JOIN users u ON u.id = sm.user_id`
+
+	member, err := scanMember(s.db.QueryRowContext(ctx, query, serverID, userID))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrAlreadyMember
+	}
+	return member, err
+}
+
+func (s *MemberService) GetMember(ctx context.Context, serverID, userID int) (*Member, error) {
+	query := memberSelect + `
+			  FROM server_members sm JOIN users u ON u.id = sm.user_id
+			  WHERE sm.server_id = $1 AND sm.user_id = $2`
+
+	return scanMember(s.db.QueryRowContext(ctx, query, serverID, userID))
+}
+
+// ListMembers pages through members ordered by user ID, starting after
+// afterUserID (0 for the first page).
+func (s *MemberService) ListMembers(ctx context.Context, serverID, afterUserID, limit int) ([]*Member, error) {
+	query := memberSelect + `
+			  FROM server_members sm JOIN users u ON u.id = sm.user_id
+			  WHERE sm.server_id = $1 AND sm.user_id > $2
+			  ORDER BY sm.user_id
+			  LIMIT $3`
+
+	rows, err := s.db.QueryContext(ctx, query, serverID, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *MemberService) RemoveMember(ctx context.Context, serverID, userID int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM server_members WHERE server_id = $1 AND user_id = $2`, serverID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanMember(row interface{ Scan(...interface{}) error }) (*Member, error) {
+	member := &Member{}
+	err := row.Scan(
+		&member.ServerID, &member.UserID, &member.Username, &member.DisplayName, &member.AvatarURL,
+		pq.Array(&member.Roles), &member.JoinedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
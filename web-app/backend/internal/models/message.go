@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type MessageAuthor struct {
+	ID          int     `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+type Message struct {
+	ID        int           `json:"id" db:"id"`
+	ChannelID int           `json:"channel_id" db:"channel_id"`
+	UserID    int           `json:"user_id" db:"user_id"`
+	Content   string        `json:"content" db:"content"`
+	Edited    bool          `json:"edited" db:"edited"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+	Author    MessageAuthor `json:"author"`
+}
+
+// MessageQuery selects a page of messages by keyset. At most one of Before
+// and After should be set; with neither the newest messages are returned.
+type MessageQuery struct {
+	Before int
+	After  int
+	Limit  int
+}
+
+type MessageService struct {
+	db *sql.DB
+}
+
+func NewMessageService(db *sql.DB) *MessageService {
+	return &MessageService{db: db}
+}
+
+const messageColumns = `m.id, m.channel_id, m.user_id, m.content, m.edited, m.created_at, m.updated_at,
+			  u.id, u.username, u.display_name, u.avatar_url`
+
+func scanMessage(row interface{ Scan(...interface{}) error }) (*Message, error) {
+	message := &Message{}
+	err := row.Scan(
+		&message.ID, &message.ChannelID, &message.UserID, &message.Content,
+		&message.Edited, &message.CreatedAt, &message.UpdatedAt,
+		&message.Author.ID, &message.Author.Username, &message.Author.DisplayName, &message.Author.AvatarURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (s *MessageService) CreateMessage(ctx context.Context, channelID, userID int, content string) (*Message, error) {
+	query := `WITH m AS (
+				  INSERT INTO messages (channel_id, user_id, content)
+				  VALUES ($1, $2, $3)
+				  RETURNING id, channel_id, user_id, content, edited, created_at, updated_at
+			  )
+			  SELECT ` + messageColumns + `
+			  FROM m JOIN users u ON u.id = m.user_id`
+
+	return scanMessage(s.db.QueryRowContext(ctx, query, channelID, userID, content))
+}
+
+func (s *MessageService) GetMessageByID(ctx context.Context, id int) (*Message, error) {
+	query := `SELECT ` + messageColumns + `
+			  FROM messages m JOIN users u ON u.id = m.user_id
+			  WHERE m.id = $1`
+
+	return scanMessage(s.db.QueryRowContext(ctx, query, id))
+}
+
+// ListMessages returns up to q.Limit messages in chronological order, plus
+// whether more messages exist beyond the page in the direction of travel.
+func (s *MessageService) ListMessages(ctx context.Context, channelID int, q MessageQuery) ([]*Message, bool, error) {
+	var query string
+	var cursor int
+
+	if q.After > 0 {
+		query = `SELECT ` + messageColumns + `
+				 FROM messages m JOIN users u ON u.id = m.user_id
+				 WHERE m.channel_id = $1 AND m.id > $2
+				 ORDER BY m.id ASC LIMIT $3`
+		cursor = q.After
+	} else {
+		query = `SELECT ` + messageColumns + `
+				 FROM messages m JOIN users u ON u.id = m.user_id
+				 WHERE m.channel_id = $1 AND ($2 = 0 OR m.id < $2)
+				 ORDER BY m.id DESC LIMIT $3`
+		cursor = q.Before
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	rows, err := s.db.QueryContext(ctx, query, channelID, cursor, q.Limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	messages := []*Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > q.Limit
+	if hasMore {
+		messages = messages[:q.Limit]
+	}
+
+	if q.After <= 0 {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, hasMore, nil
+}
+
+func (s *MessageService) UpdateMessage(ctx context.Context, id int, content string) (*Message, error) {
+	query := `WITH m AS (
+				  UPDATE messages SET content = $2, edited = TRUE, updated_at = CURRENT_TIMESTAMP
+				  WHERE id = $1
+				  RETURNING id, channel_id, user_id, content, edited, created_at, updated_at
+			  )
+			  SELECT ` + messageColumns + `
+			  FROM m JOIN users u ON u.id = m.user_id`
+
+	return scanMessage(s.db.QueryRowContext(ctx, query, id, content))
+}
+
+func (s *MessageService) DeleteMessage(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM messages WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
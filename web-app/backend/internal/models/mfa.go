@@ -0,0 +1,313 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// MFAChallengeTTL is how long a sign-in waits for its second factor.
+	MFAChallengeTTL = 5 * time.Minute
+	// MaxMFAAttempts is how many codes can be tried against one challenge,
+	// and by a signed-in user per MFAAttemptWindow.
+	MaxMFAAttempts = 5
+	// MFAAttemptWindow is how long a signed-in user's attempts are counted.
+	MFAAttemptWindow = 15 * time.Minute
+	// RecoveryCodeCount is how many recovery codes are issued at a time.
+	RecoveryCodeCount = 10
+)
+
+var (
+	// ErrTOTPAlreadyEnabled is returned when enrolling a user who has 2FA.
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnabled is returned for 2FA operations on users without it.
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidMFATicket is returned for unknown, expired or exhausted
+	// challenge tickets.
+	ErrInvalidMFATicket = errors.New("invalid MFA ticket")
+	// ErrMFAAttemptsExceeded is returned once a signed-in user has used up
+	// MaxMFAAttempts in the current MFAAttemptWindow.
+	ErrMFAAttemptsExceeded = errors.New("too many two-factor attempts")
+)
+
+// TOTPState is a user's authenticator enrollment. Secret is set from the
+// start of enrollment; Enabled once it was confirmed with a code.
+type TOTPState struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// MFAChallenge is a sign-in waiting for its second factor.
+type MFAChallenge struct {
+	UserID     int
+	DeviceName string
+}
+
+type MFAService struct {
+	db *sql.DB
+}
+
+func NewMFAService(db *sql.DB) *MFAService {
+	return &MFAService{db: db}
+}
+
+// GetTOTPState returns the user's enrollment; Secret is empty if none was
+// started.
+func (s *MFAService) GetTOTPState(ctx context.Context, userID int) (*TOTPState, error) {
+	state := &TOTPState{}
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step
+						  FROM users WHERE id = $1`, userID).Scan(&secret, &state.Enabled, &lastStep)
+	if err != nil {
+		return nil, err
+	}
+	state.Secret = secret.String
+	state.LastStep = lastStep.Int64
+	return state, nil
+}
+
+// BeginTOTPEnrollment stores a new, not yet confirmed secret, replacing
+// any earlier unconfirmed one.
+func (s *MFAService) BeginTOTPEnrollment(ctx context.Context, userID int, secret string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET totp_secret = $2
+							  WHERE id = $1 AND totp_enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns on 2FA after the user confirmed the pending secret with
+// the code for step, and returns a fresh set of recovery codes.
+func (s *MFAService) EnableTOTP(ctx context.Context, userID int, step int64) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2
+							WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, userID, step)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// DisableTOTP turns off 2FA and deletes the user's recovery codes.
+func (s *MFAService) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+					  WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that the code for step was accepted. It returns
+// false if that step (or a later one) was already used, so a code cannot
+// be replayed.
+func (s *MFAService) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET totp_last_step = $2
+							  WHERE id = $1 AND totp_enabled_at IS NOT NULL
+							    AND (totp_last_step IS NULL OR totp_last_step < $2)`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes as used.
+// It returns false if the code does not match any of them.
+func (s *MFAService) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+							  WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRowContext(ctx, `SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&enabled)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has.
+func (s *MFAService) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM recovery_codes
+						  WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// CreateChallenge starts a second-factor challenge for a sign-in and
+// returns its ticket.
+func (s *MFAService) CreateChallenge(ctx context.Context, userID int, deviceName string) (string, error) {
+	ticket, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Expired challenges are never redeemed; clear them out as new ones arrive
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at <= $1`, time.Now()); err != nil {
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO mfa_challenges (ticket_hash, user_id, device_name, expires_at)
+						VALUES ($1, $2, $3, $4)`, hashToken(ticket), userID, deviceName, time.Now().Add(MFAChallengeTTL))
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// AttemptChallenge counts one verification attempt against a ticket and
+// returns the challenge. Returns ErrInvalidMFATicket once the ticket has
+// expired or used up MaxMFAAttempts.
+func (s *MFAService) AttemptChallenge(ctx context.Context, ticket string) (*MFAChallenge, error) {
+	challenge := &MFAChallenge{}
+	err := s.db.QueryRowContext(ctx, `UPDATE mfa_challenges SET attempts = attempts + 1
+						  WHERE ticket_hash = $1 AND expires_at > $2 AND attempts < $3
+						  RETURNING user_id, device_name`, hashToken(ticket), time.Now(), MaxMFAAttempts).
+		Scan(&challenge.UserID, &challenge.DeviceName)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidMFATicket
+	}
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge deletes a ticket after its second factor was verified.
+// Returns ErrInvalidMFATicket if it was already completed, so one ticket
+// cannot start two sessions.
+func (s *MFAService) CompleteChallenge(ctx context.Context, ticket string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE ticket_hash = $1`, hashToken(ticket))
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrInvalidMFATicket
+	}
+	return nil
+}
+
+// AttemptSecondFactor counts one code attempt by a signed-in user. The
+// window starts at the first attempt after the previous one ended.
+// Returns ErrMFAAttemptsExceeded once MaxMFAAttempts have been made in it.
+func (s *MFAService) AttemptSecondFactor(ctx context.Context, userID int) error {
+	now := time.Now()
+	var id int
+	err := s.db.QueryRowContext(ctx, `UPDATE users SET
+							mfa_attempts = CASE WHEN mfa_attempts_reset_at IS NULL OR mfa_attempts_reset_at <= $2
+											   THEN 1 ELSE mfa_attempts + 1 END,
+							mfa_attempts_reset_at = CASE WHEN mfa_attempts_reset_at IS NULL OR mfa_attempts_reset_at <= $2
+														THEN $3 ELSE mfa_attempts_reset_at END
+						  WHERE id = $1 AND (mfa_attempts_reset_at IS NULL OR mfa_attempts_reset_at <= $2 OR mfa_attempts < $4)
+						  RETURNING id`, userID, now, now.Add(MFAAttemptWindow), MaxMFAAttempts).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrMFAAttemptsExceeded
+	}
+	return err
+}
+
+// ResetSecondFactorAttempts clears the user's attempt count after a code
+// was accepted.
+func (s *MFAService) ResetSecondFactorAttempts(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET mfa_attempts = 0, mfa_attempts_reset_at = NULL
+						WHERE id = $1`, userID)
+	return err
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and inserts
+// RecoveryCodeCount new ones, returned formatted as "xxxxx-xxxxx".
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10] // 50 bits
+		codes[i] = code[:5] + "-" + code[5:]
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hashToken(code))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode lowercases a code and drops separators so codes
+// can be typed with or without the dash.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
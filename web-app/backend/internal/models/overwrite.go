@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/user/web-app/internal/permissions"
+)
+
+type Overwrite struct {
+	ChannelID int                       `json:"channel_id" db:"channel_id"`
+	Type      permissions.OverwriteType `json:"type"`
+	TargetID  int                       `json:"id"`
+	Allow     permissions.Permission    `json:"allow" db:"allow"`
+	Deny      permissions.Permission    `json:"deny" db:"deny"`
+}
+
+type OverwriteService struct {
+	db *sql.DB
+}
+
+func NewOverwriteService(db *sql.DB) *OverwriteService {
+	return &OverwriteService{db: db}
+}
+
+// overwriteColumns selects an overwrite as (channel_id, type, target_id, allow, deny)
+const overwriteColumns = `channel_id,
+				 CASE WHEN role_id IS NOT NULL THEN 'role' ELSE 'member' END,
+				 COALESCE(role_id, user_id), allow, deny`
+
+func scanOverwrite(row interface{ Scan(...interface{}) error }) (*Overwrite, error) {
+	o := &Overwrite{}
+	if err := row.Scan(&o.ChannelID, &o.Type, &o.TargetID, &o.Allow, &o.Deny); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Permission converts the overwrite for permissions.ComputeChannel.
+func (o *Overwrite) Permission() permissions.Overwrite {
+	return permissions.Overwrite{Type: o.Type, TargetID: o.TargetID, Allow: o.Allow, Deny: o.Deny}
+}
+
+func (s *OverwriteService) ListOverwrites(ctx context.Context, channelID int) ([]*Overwrite, error) {
+	return s.queryOverwrites(ctx, `SELECT `+overwriteColumns+`
+							  FROM channel_overwrites WHERE channel_id = $1
+							  ORDER BY id`, channelID)
+}
+
+// ListServerOverwrites returns the overwrites of every channel in a server,
+// keyed by channel ID.
+func (s *OverwriteService) ListServerOverwrites(ctx context.Context, serverID int) (map[int][]permissions.Overwrite, error) {
+	overwrites, err := s.queryOverwrites(ctx, `SELECT `+overwriteColumns+`
+										  FROM channel_overwrites
+										  WHERE channel_id IN (SELECT id FROM channels WHERE server_id = $1)
+										  ORDER BY id`, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	byChannel := make(map[int][]permissions.Overwrite)
+	for _, o := range overwrites {
+		byChannel[o.ChannelID] = append(byChannel[o.ChannelID], o.Permission())
+	}
+	return byChannel, nil
+}
+
+// GetChannelOverwrites returns a channel's overwrites in the form
+// permissions.ComputeChannel expects.
+func (s *OverwriteService) GetChannelOverwrites(ctx context.Context, channelID int) ([]permissions.Overwrite, error) {
+	overwrites, err := s.ListOverwrites(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]permissions.Overwrite, len(overwrites))
+	for i, o := range overwrites {
+		result[i] = o.Permission()
+	}
+	return result, nil
+}
+
+// SetOverwrite creates or replaces the overwrite for a role or member.
+func (s *OverwriteService) SetOverwrite(ctx context.Context, channelID int, overwriteType permissions.OverwriteType, targetID int, allow, deny permissions.Permission) (*Overwrite, error) {
+	column := "role_id"
+	if overwriteType == permissions.OverwriteMember {
+		column = "user_id"
+	}
+
+	query := `INSERT INTO channel_overwrites (channel_id, ` + column + `, allow, deny)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (channel_id, ` + column + `) WHERE ` + column + ` IS NOT NULL
+			  DO UPDATE SET allow = EXCLUDED.allow, deny = EXCLUDED.deny
+			  RETURNING ` + overwriteColumns
+
+	return scanOverwrite(s.db.QueryRowContext(ctx, query, channelID, targetID, allow, deny))
+}
+
+func (s *OverwriteService) DeleteOverwrite(ctx context.Context, channelID int, overwriteType permissions.OverwriteType, targetID int) error {
+	column := "role_id"
+	if overwriteType == permissions.OverwriteMember {
+		column = "user_id"
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM channel_overwrites WHERE channel_id = $1 AND `+column+` = $2`, channelID, targetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *OverwriteService) queryOverwrites(ctx context.Context, query string, args ...interface{}) ([]*Overwrite, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overwrites := []*Overwrite{}
+	for rows.Next() {
+		o, err := scanOverwrite(rows)
+		if err != nil {
+			return nil, err
+		}
+		overwrites = append(overwrites, o)
+	}
+
+	return overwrites, rows.Err()
+}
+
+// ChannelViewers reports which of userIDs can view a channel, computing
+// everyone's permissions with a fixed number of queries. Users who are not
+// members of the channel's server are never viewers. Returns sql.ErrNoRows
+// if the channel does not exist.
+func (s *OverwriteService) ChannelViewers(ctx context.Context, channelID int, userIDs []int) (map[int]bool, error) {
+	var serverID sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT server_id FROM channels WHERE id = $1`, channelID).Scan(&serverID); err != nil {
+		return nil, err
+	}
+	if !serverID.Valid {
+		return nil, sql.ErrNoRows // DM channels have no server
+	}
+
+	inputs, err := loadPermissionInputs(ctx, s.db, int(serverID.Int64), userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	overwrites, err := s.GetChannelOverwrites(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	viewers := make(map[int]bool, len(inputs))
+	for userID, in := range inputs {
+		if in.IsMember && permissions.ComputeChannel(*in, overwrites).Permissions.Has(permissions.ViewChannels) {
+			viewers[userID] = true
+		}
+	}
+	return viewers, nil
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used after it is issued.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked tokens.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated token is
+	// presented again; its whole family has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+type RefreshTokenService struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenService(db *sql.DB) *RefreshTokenService {
+	return &RefreshTokenService{db: db}
+}
+
+// RotateRefreshToken exchanges a valid refresh token for a new one in the
+// same family and returns the owning user and session. Presenting a token
+// that was already rotated revokes the session and returns its ID with
+// ErrRefreshTokenReused.
+func (s *RefreshTokenService) RotateRefreshToken(ctx context.Context, token string) (userID int, sessionID, newToken string, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+					   FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, hashToken(token)).
+		Scan(&id, &userID, &sessionID, &expiresAt, &usedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return 0, "", "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return 0, "", "", ErrInvalidRefreshToken
+	}
+	if usedAt.Valid {
+		if err := revokeSession(ctx, tx, sessionID); err != nil {
+			return 0, "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", "", err
+		}
+		return 0, sessionID, "", ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1`, id); err != nil {
+		return 0, "", "", err
+	}
+	newToken, err = insertRefreshToken(ctx, tx, userID, sessionID)
+	if err != nil {
+		return 0, "", "", err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET expires_at = $2 WHERE id = $1`, sessionID, time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", "", err
+	}
+	return userID, sessionID, newToken, nil
+}
+
+// RevokeRefreshToken revokes the session a refresh token belongs to and
+// returns its ID. Returns ErrInvalidRefreshToken if the token is unknown.
+func (s *RefreshTokenService) RevokeRefreshToken(ctx context.Context, token string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var sessionID string
+	err = tx.QueryRowContext(ctx, `SELECT family_id FROM refresh_tokens WHERE token_hash = $1`, hashToken(token)).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := revokeSession(ctx, tx, sessionID); err != nil {
+		return "", err
+	}
+	return sessionID, tx.Commit()
+}
+
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, userID int, sessionID string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+					  VALUES ($1, $2, $3, $4)`, userID, sessionID, hashToken(token), time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeSession marks a session and its refresh token family revoked.
+// Callers run it inside a transaction so both updates land together.
+func revokeSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+					   WHERE family_id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+					  WHERE id = $1 AND revoked_at IS NULL`, sessionID)
+	return err
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/user/web-app/internal/permissions"
+)
+
+// DefaultRoleName is the name of every server's is_default role.
+const DefaultRoleName = "@everyone"
+
+// ErrRoleNotInServer is returned when a reorder references a role that
+// belongs to a different server, does not exist, or is @everyone.
+var ErrRoleNotInServer = errors.New("role does not belong to server")
+
+type Role struct {
+	ID          int                    `json:"id" db:"id"`
+	ServerID    int                    `json:"server_id" db:"server_id"`
+	Name        string                 `json:"name" db:"name"`
+	Color       int                    `json:"color" db:"color"`
+	Position    int                    `json:"position" db:"position"`
+	Permissions permissions.Permission `json:"permissions" db:"permissions"`
+	IsDefault   bool                   `json:"is_default" db:"is_default"`
+	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+type RolePosition struct {
+	ID       int `json:"id"`
+	Position int `json:"position"`
+}
+
+// RoleUpdate holds the optional fields of a role edit; nil means unchanged.
+type RoleUpdate struct {
+	Name        *string
+	Color       *int
+	Permissions *permissions.Permission
+}
+
+type RoleService struct {
+	db *sql.DB
+}
+
+func NewRoleService(db *sql.DB) *RoleService {
+	return &RoleService{db: db}
+}
+
+const roleColumns = `id, server_id, name, color, position, permissions, is_default, created_at, updated_at`
+
+func scanRole(row interface{ Scan(...interface{}) error }) (*Role, error) {
+	role := &Role{}
+	err := row.Scan(
+		&role.ID, &role.ServerID, &role.Name, &role.Color, &role.Position,
+		&role.Permissions, &role.IsDefault, &role.CreatedAt, &role.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (s *RoleService) ListRoles(ctx context.Context, serverID int) ([]*Role, error) {
+	query := `SELECT ` + roleColumns + `
+			  FROM roles WHERE server_id = $1
+			  ORDER BY position DESC, id`
+
+	rows, err := s.db.QueryContext(ctx, query, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (s *RoleService) GetRole(ctx context.Context, serverID, roleID int) (*Role, error) {
+	query := `SELECT ` + roleColumns + ` FROM roles WHERE server_id = $1 AND id = $2`
+	return scanRole(s.db.QueryRowContext(ctx, query, serverID, roleID))
+}
+
+// CreateRole inserts the role at position 1, directly above @everyone,
+// shifting existing roles up so positions stay unique.
+func (s *RoleService) CreateRole(ctx context.Context, serverID int, name string, color int, perms permissions.Permission) (*Role, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE roles SET position = position + 1
+					  WHERE server_id = $1 AND NOT is_default`, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO roles (server_id, name, color, position, permissions)
+			  VALUES ($1, $2, $3, 1, $4)
+			  RETURNING ` + roleColumns
+
+	role, err := scanRole(tx.QueryRowContext(ctx, query, serverID, name, color, perms))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (s *RoleService) UpdateRole(ctx context.Context, serverID, roleID int, update RoleUpdate) (*Role, error) {
+	query := `UPDATE roles
+			  SET name = COALESCE($3, name), color = COALESCE($4, color),
+				  permissions = COALESCE($5, permissions), updated_at = CURRENT_TIMESTAMP
+			  WHERE server_id = $1 AND id = $2
+			  RETURNING ` + roleColumns
+
+	return scanRole(s.db.QueryRowContext(ctx, query, serverID, roleID, update.Name, update.Color, update.Permissions))
+}
+
+func (s *RoleService) DeleteRole(ctx context.Context, serverID, roleID int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM roles WHERE server_id = $1 AND id = $2 AND NOT is_default`, serverID, roleID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ReorderRoles applies all position updates in a single transaction.
+// @everyone always stays at position 0; if any role is not part of the
+// server (or is @everyone) nothing is changed.
+func (s *RoleService) ReorderRoles(ctx context.Context, serverID int, positions []RolePosition) ([]*Role, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE roles SET position = $3, updated_at = CURRENT_TIMESTAMP
+							 WHERE id = $1 AND server_id = $2 AND NOT is_default`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, p := range positions {
+		result, err := stmt.ExecContext(ctx, p.ID, serverID, p.Position)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, ErrRoleNotInServer
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.ListRoles(ctx, serverID)
+}
+
+// AssignRole is idempotent; assigning a role twice is not an error.
+func (s *RoleService) AssignRole(ctx context.Context, serverID, userID, roleID int) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO member_roles (server_id, user_id, role_id)
+						 VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, serverID, userID, roleID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		// Foreign key violation: the user is not a member of the server
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func (s *RoleService) UnassignRole(ctx context.Context, serverID, userID, roleID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM member_roles WHERE server_id = $1 AND user_id = $2 AND role_id = $3`,
+		serverID, userID, roleID)
+	return err
+}
+
+// GetPermissionInputs loads everything permissions.Compute needs for a
+// user in a server. Returns sql.ErrNoRows if the server does not exist.
+func (s *RoleService) GetPermissionInputs(ctx context.Context, serverID, userID int) (permissions.Inputs, error) {
+	inputs, err := loadPermissionInputs(ctx, s.db, serverID, []int{userID})
+	if err != nil {
+		return permissions.Inputs{UserID: userID}, err
+	}
+	return *inputs[userID], nil
+}
+
+// loadPermissionInputs builds permissions.Inputs for each of userIDs in a
+// server with a fixed number of queries. Every user gets an entry; those
+// who are not members have IsMember false. Shared by REST permission
+// checks and gateway channel filtering so both always agree. Returns
+// sql.ErrNoRows if the server does not exist.
+func loadPermissionInputs(ctx context.Context, db *sql.DB, serverID int, userIDs []int) (map[int]*permissions.Inputs, error) {
+	var ownerID, everyoneID, everyone sql.NullInt64
+	var requireMFA bool
+
+	query := `SELECT s.owner_id, s.mfa_required, r.id, r.permissions
+			  FROM servers s
+			  LEFT JOIN roles r ON r.server_id = s.id AND r.is_default
+			  WHERE s.id = $1`
+
+	if err := db.QueryRowContext(ctx, query, serverID).Scan(&ownerID, &requireMFA, &everyoneID, &everyone); err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[int]*permissions.Inputs, len(userIDs))
+	for _, userID := range userIDs {
+		isOwner := ownerID.Valid && int(ownerID.Int64) == userID
+		inputs[userID] = &permissions.Inputs{
+			UserID:     userID,
+			IsOwner:    isOwner,
+			IsMember:   isOwner,
+			EveryoneID: int(everyoneID.Int64),
+			Everyone:   permissions.Permission(everyone.Int64),
+			RequireMFA: requireMFA,
+		}
+	}
+
+	// One row per (user, role); users without roles get a single row with a NULL role
+	rows, err := db.QueryContext(ctx, `SELECT u.id, u.totp_enabled_at IS NOT NULL, sm.user_id IS NOT NULL,
+									r.id, r.position, r.permissions
+							 FROM users u
+							 LEFT JOIN server_members sm ON sm.server_id = $1 AND sm.user_id = u.id
+							 LEFT JOIN member_roles mr ON mr.server_id = $1 AND mr.user_id = sm.user_id
+							 LEFT JOIN roles r ON r.id = mr.role_id
+							 WHERE u.id = ANY($2)`, serverID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		var mfaEnabled, isMember bool
+		var roleID, position, perms sql.NullInt64
+		if err := rows.Scan(&userID, &mfaEnabled, &isMember, &roleID, &position, &perms); err != nil {
+			return nil, err
+		}
+
+		in := inputs[userID]
+		in.MFAEnabled = mfaEnabled
+		in.IsMember = in.IsMember || isMember
+		if roleID.Valid && !in.IsOwner {
+			in.Roles = append(in.Roles, permissions.Role{
+				ID:          int(roleID.Int64),
+				Position:    int(position.Int64),
+				Permissions: permissions.Permission(perms.Int64),
+			})
+		}
+	}
+
+	return inputs, rows.Err()
+}
+
+// createDefaultRole inserts @everyone for a new server inside tx.
+func createDefaultRole(ctx context.Context, tx *sql.Tx, serverID int) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO roles (server_id, name, position, permissions, is_default)
+					   VALUES ($1, $2, 0, $3, TRUE)`, serverID, DefaultRoleName, permissions.DefaultEveryone)
+	return err
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Server struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	OwnerID     int       `json:"owner_id" db:"owner_id"`
+	IconURL     *string   `json:"icon_url" db:"icon_url"`
+	MFARequired bool      `json:"mfa_required" db:"mfa_required"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ServerService struct {
+	db *sql.DB
+}
+
+func NewServerService(db *sql.DB) *ServerService {
+	return &ServerService{db: db}
+}
+
+// CreateServer inserts the server, its @everyone role and the owner's
+// membership row in one transaction so the owner always shows up in the
+// member list.
+func (s *ServerService) CreateServer(ctx context.Context, name string, ownerID int, iconURL *string) (*Server, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	server := &Server{}
+	query := `INSERT INTO servers (name, owner_id, icon_url)
+			  VALUES ($1, $2, $3)
+			  RETURNING id, name, owner_id, icon_url, mfa_required, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query, name, ownerID, iconURL).Scan(
+		&server.ID, &server.Name, &server.OwnerID, &server.IconURL, &server.MFARequired,
+		&server.CreatedAt, &server.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createDefaultRole(ctx, tx, server.ID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO server_members (server_id, user_id) VALUES ($1, $2)`, server.ID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+func (s *ServerService) GetServerByID(ctx context.Context, id int) (*Server, error) {
+	server := &Server{}
+	query := `SELECT id, name, owner_id, icon_url, mfa_required, created_at, updated_at
+			  FROM servers WHERE id = $1`
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&server.ID, &server.Name, &server.OwnerID, &server.IconURL, &server.MFARequired,
+		&server.CreatedAt, &server.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// UpdateServer only overwrites the fields that are non-nil.
+func (s *ServerService) UpdateServer(ctx context.Context, id int, name, iconURL *string, mfaRequired *bool) (*Server, error) {
+	server := &Server{}
+	query := `UPDATE servers
+			  SET name = COALESCE($2, name), icon_url = COALESCE($3, icon_url),
+				  mfa_required = COALESCE($4, mfa_required), updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1
+			  RETURNING id, name, owner_id, icon_url, mfa_required, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, id, name, iconURL, mfaRequired).Scan(
+		&server.ID, &server.Name, &server.OwnerID, &server.IconURL, &server.MFARequired,
+		&server.CreatedAt, &server.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+func (s *ServerService) DeleteServer(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM servers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetServersForUser returns every server the user owns or has joined.
+func (s *ServerService) GetServersForUser(ctx context.Context, userID int) ([]*Server, error) {
+	query := `SELECT id, name, owner_id, icon_url, mfa_required, created_at, updated_at
+			  FROM servers
+			  WHERE owner_id = $1
+			     OR id IN (SELECT server_id FROM server_members WHERE user_id = $1)
+			  ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	servers := []*Server{}
+	for rows.Next() {
+		server := &Server{}
+		if err := rows.Scan(
+			&server.ID, &server.Name, &server.OwnerID, &server.IconURL, &server.MFARequired,
+			&server.CreatedAt, &server.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+
+	return servers, rows.Err()
+}
+
+// GetServerIDsForUser is a lighter GetServersForUser used by the gateway
+// to decide which server events a connection should receive.
+func (s *ServerService) GetServerIDsForUser(ctx context.Context, userID int) ([]int, error) {
+	query := `SELECT id FROM servers WHERE owner_id = $1
+			  UNION
+			  SELECT server_id FROM server_members WHERE user_id = $1`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is one sign-in on one device. Its ID is the jti claim of the
+// access tokens issued for it.
+type Session struct {
+	ID         string    `json:"id" db:"id"`
+	UserID     int       `json:"-" db:"user_id"`
+	DeviceName string    `json:"device_name" db:"device_name"`
+	IPAddress  string    `json:"ip_address" db:"ip_address"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type SessionService struct {
+	db *sql.DB
+}
+
+func NewSessionService(db *sql.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+const sessionColumns = `id, user_id, device_name, ip_address, user_agent, created_at, last_seen_at, expires_at`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
+	session := &Session{}
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.DeviceName, &session.IPAddress,
+		&session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// CreateSession starts a session for userID and issues its first refresh
+// token, returning both.
+func (s *SessionService) CreateSession(ctx context.Context, userID int, deviceName, ipAddress, userAgent string) (*Session, string, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO sessions (id, user_id, device_name, ip_address, user_agent, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  RETURNING ` + sessionColumns
+
+	session, err := scanSession(tx.QueryRowContext(ctx, query, id, userID, deviceName, ipAddress, userAgent, time.Now().Add(RefreshTokenTTL)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, err := insertRefreshToken(ctx, tx, userID, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// ListSessions returns the user's active sessions, most recently seen first.
+func (s *SessionService) ListSessions(ctx context.Context, userID int) ([]*Session, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM sessions
+							 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+							 ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// TouchSession bumps last_seen_at and reports whether the session is still
+// active (exists, not revoked and not expired).
+func (s *SessionService) TouchSession(ctx context.Context, id string) (bool, error) {
+	var found string
+	err := s.db.QueryRowContext(ctx, `UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP
+						  WHERE id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+						  RETURNING id`, id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeSession revokes one of the user's active sessions and its refresh
+// tokens. Returns sql.ErrNoRows if the user has no such active session.
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM sessions
+					   WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+					   FOR UPDATE`, id, userID).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeSession(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,19 +1,38 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrUsernameTaken is returned when a username is already in use.
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrEmailTaken is returned when an email is already registered.
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrUsernameChangeTooSoon is returned when a user changes their
+	// username again within UsernameChangeCooldown.
+	ErrUsernameChangeTooSoon = errors.New("username was changed too recently")
 )
 
+// UsernameChangeCooldown is the minimum time between username changes, so
+// handles cannot be cycled rapidly or freed and claimed in bursts.
+const UsernameChangeCooldown = 24 * time.Hour
+
 type User struct {
 	ID           int       `json:"id" db:"id"`
 	Username     string    `json:"username" db:"username"`
+	DisplayName  *string   `json:"display_name" db:"display_name"`
 	Email        string    `json:"email" db:"email"`
 	PasswordHash *string   `json:"-" db:"password_hash"`
-	GoogleID     *string   `json:"google_id" db:"google_id"`
 	Provider     string    `json:"provider" db:"provider"`
 	AvatarURL    *string   `json:"avatar_url" db:"avatar_url"`
 	Status       string    `json:"status" db:"status"`
+	MFAEnabled   bool      `json:"mfa_enabled" db:"-"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -26,14 +45,16 @@ func NewUserService(db *sql.DB) *UserService {
 	return &UserService{db: db}
 }
 
-func (s *UserService) GetUserByGoogleID(googleID string) (*User, error) {
+// GetUserByProviderID finds the user a login provider identity is linked to.
+func (s *UserService) GetUserByProviderID(ctx context.Context, provider, providerUserID string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, email, password_hash, google_id, provider, avatar_url, status, created_at, updated_at 
-			  FROM users WHERE google_id = $1`
+	query := `SELECT u.id, u.username, u.display_name, u.email, u.password_hash, u.provider, u.avatar_url, u.status, u.totp_enabled_at IS NOT NULL, u.created_at, u.updated_at 
+			  FROM users u JOIN user_identities i ON i.user_id = u.id
+			  WHERE i.provider = $1 AND i.subject = $2`
 	
-	err := s.db.QueryRow(query, googleID).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.GoogleID, &user.Provider, &user.AvatarURL, &user.Status,
+	err := s.db.QueryRowContext(ctx, query, provider, providerUserID).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 	
@@ -44,14 +65,14 @@ func (s *UserService) GetUserByGoogleID(googleID string) (*User, error) {
 	return user, nil
 }
 
-func (s *UserService) GetUserByEmail(email string) (*User, error) {
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, email, password_hash, google_id, provider, avatar_url, status, created_at, updated_at 
+	query := `SELECT id, username, display_name, email, password_hash, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at 
 			  FROM users WHERE email = $1`
 	
-	err := s.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.GoogleID, &user.Provider, &user.AvatarURL, &user.Status,
+	err := s.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 	
@@ -62,21 +83,148 @@ func (s *UserService) GetUserByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-func (s *UserService) CreateOAuthUser(email, username, googleID, avatarURL string) (*User, error) {
+// CreateOAuthUser inserts a user who signs in through a login provider,
+// together with the linked identity. Returns ErrUsernameTaken,
+// ErrEmailTaken or ErrIdentityLinked on unique violations.
+func (s *UserService) CreateOAuthUser(ctx context.Context, provider, providerUserID, email, username, displayName, avatarURL string) (*User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	user := &User{}
-	query := `INSERT INTO users (username, email, google_id, provider, avatar_url, status) 
-			  VALUES ($1, $2, $3, 'google', $4, 'online') 
-			  RETURNING id, username, email, google_id, provider, avatar_url, status, created_at, updated_at`
+	query := `INSERT INTO users (username, display_name, email, provider, avatar_url, status) 
+			  VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), 'online') 
+			  RETURNING id, username, display_name, email, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at`
 	
-	err := s.db.QueryRow(query, username, email, googleID, avatarURL).Scan(
-		&user.ID, &user.Username, &user.Email, &user.GoogleID,
-		&user.Provider, &user.AvatarURL, &user.Status,
+	err = tx.QueryRowContext(ctx, query, username, displayName, email, provider, avatarURL).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
-	
 	if err != nil {
+		return nil, uniqueUserError(err)
+	}
+
+	if _, err := insertIdentity(ctx, tx, user.ID, provider, providerUserID, email); err != nil {
 		return nil, err
 	}
 	
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateLocalUser inserts a user who signs in with email and password.
+// Returns ErrUsernameTaken or ErrEmailTaken on unique violations.
+func (s *UserService) CreateLocalUser(ctx context.Context, username, displayName, email, passwordHash string) (*User, error) {
+	user := &User{}
+	query := `INSERT INTO users (username, display_name, email, password_hash, provider)
+			  VALUES ($1, NULLIF($2, ''), $3, $4, 'local')
+			  RETURNING id, username, display_name, email, password_hash, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, username, displayName, email, passwordHash).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, uniqueUserError(err)
+	}
+
+	return user, nil
+}
+
+// uniqueUserError maps unique violations on users to ErrUsernameTaken or
+// ErrEmailTaken and returns any other error unchanged.
+func uniqueUserError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		switch pqErr.Constraint {
+		case "users_username_key":
+			return ErrUsernameTaken
+		case "users_email_key":
+			return ErrEmailTaken
+		}
+	}
+	return err
+}
+
+func (s *UserService) GetUserByID(ctx context.Context, id int) (*User, error) {
+	user := &User{}
+	query := `SELECT id, username, display_name, email, password_hash, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at
+			  FROM users WHERE id = $1`
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateDisplayName sets a user's display name; an empty name clears it.
+func (s *UserService) UpdateDisplayName(ctx context.Context, id int, displayName string) (*User, error) {
+	user := &User{}
+	query := `UPDATE users SET display_name = NULLIF($2, ''), updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1
+			  RETURNING id, username, display_name, email, password_hash, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, id, displayName).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
-}
\ No newline at end of file
+}
+
+// ChangeUsername gives a user a new username. Changes are limited to one
+// per UsernameChangeCooldown; when refused the error is
+// ErrUsernameChangeTooSoon and retryAfter says how long to wait. Setting
+// the current username again is a no-op.
+func (s *UserService) ChangeUsername(ctx context.Context, id int, username string) (user *User, retryAfter time.Duration, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var changedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `SELECT username, username_changed_at FROM users
+					   WHERE id = $1 FOR UPDATE`, id).Scan(&current, &changedAt)
+	if err != nil {
+		return nil, 0, err
+	}
+	if current != username && changedAt.Valid {
+		if wait := time.Until(changedAt.Time.Add(UsernameChangeCooldown)); wait > 0 {
+			return nil, wait, ErrUsernameChangeTooSoon
+		}
+	}
+
+	user = &User{}
+	query := `UPDATE users SET username = $2, updated_at = CURRENT_TIMESTAMP,
+				  username_changed_at = CASE WHEN username = $2 THEN username_changed_at ELSE $3 END
+			  WHERE id = $1
+			  RETURNING id, username, display_name, email, password_hash, provider, avatar_url, status, totp_enabled_at IS NOT NULL, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query, id, username, time.Now()).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash,
+		&user.Provider, &user.AvatarURL, &user.Status, &user.MFAEnabled,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, 0, uniqueUserError(err)
+	}
+
+	return user, 0, tx.Commit()
+}
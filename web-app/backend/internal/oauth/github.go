@@ -0,0 +1,91 @@
+/**
+ * github.go - GitHub Login Provider
+ *
+ * GitHub is plain OAuth 2.0 without ID tokens, so the identity comes from
+ * the REST API: GET /user for the profile and GET /user/emails for the
+ * primary verified address (profile emails can be hidden or unverified).
+ */
+
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+type githubProvider struct {
+	name   string
+	oauth  *oauth2.Config
+	client *http.Client
+}
+
+func newGitHubProvider(cfg ProviderConfig, client *http.Client) *githubProvider {
+	return &githubProvider{
+		name: cfg.Name,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint:     github.Endpoint,
+		},
+		client: client,
+	}
+}
+
+func (p *githubProvider) Name() string {
+	return p.name
+}
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	return p.oauth.AuthCodeURL(state), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("code exchange: %w", err)
+	}
+
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, p.client, githubAPIURL+"/user", token, &user); err != nil {
+		return nil, fmt.Errorf("fetching GitHub user: %w", err)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, githubAPIURL+"/user/emails", token, &emails); err != nil {
+		return nil, fmt.Errorf("fetching GitHub emails: %w", err)
+	}
+
+	identity := &Identity{
+		Provider:  p.name,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Username:  user.Login,
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+	}
+	for _, e := range emails {
+		if e.Verified && (e.Primary || identity.Email == "") {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+		}
+	}
+	return identity, nil
+}
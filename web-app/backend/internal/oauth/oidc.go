@@ -0,0 +1,255 @@
+/**
+ * oidc.go - OpenID Connect Provider
+ *
+ * Endpoints are discovered from <issuer>/.well-known/openid-configuration
+ * on first use. After the code exchange the ID token is verified:
+ * - Signature against the issuer's JWKS (asymmetric algorithms only)
+ * - iss matches the issuer, aud contains our client ID, exp in the future
+ * - nonce matches the one sent with the authorization request
+ *
+ * Claims missing from the ID token (usually email) are filled in from the
+ * userinfo endpoint when the provider has one.
+ */
+
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// asymmetricAlgs are the ID token algorithms we accept. HMAC algorithms
+// are excluded because they would turn the client secret into a key.
+var asymmetricAlgs = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"EdDSA": true,
+}
+
+// discovery is the subset of the provider metadata document we use.
+type discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// oidcClaims are the ID token / userinfo claims mapped onto Identity.
+type oidcClaims struct {
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+	Nickname          string       `json:"nickname"`
+	Picture           string       `json:"picture"`
+	Nonce             string       `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// flexibleBool accepts true/false as JSON booleans or strings; some
+// providers send email_verified as "true".
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexibleBool(s == "true")
+	return nil
+}
+
+type oidcProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	meta   *discovery     // nil until discovered
+	oauth  *oauth2.Config // Built from meta
+	keys   *keySet        // Built from meta
+	verify []string       // Accepted ID token algorithms
+}
+
+func newOIDCProvider(cfg ProviderConfig, client *http.Client) *oidcProvider {
+	return &oidcProvider{cfg: cfg, client: client}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	return p.oauth.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("code exchange: %w", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	claims, err := p.verifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if claims.Email == "" && p.meta.UserinfoEndpoint != "" {
+		if err := p.fillFromUserinfo(ctx, token, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Nickname
+	}
+	return &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Username:      username,
+		Name:          claims.Name,
+		AvatarURL:     claims.Picture,
+	}, nil
+}
+
+// discover loads the provider metadata once; failures are retried on the
+// next call.
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return nil
+	}
+
+	url := p.cfg.Issuer + "/.well-known/openid-configuration"
+	var meta discovery
+	if err := getJSON(ctx, p.client, url, nil, &meta); err != nil {
+		return fmt.Errorf("discovery for %s: %w", p.cfg.Name, err)
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return fmt.Errorf("discovery for %s: issuer %q does not match %q", p.cfg.Name, meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return fmt.Errorf("discovery for %s: missing endpoints", p.cfg.Name)
+	}
+
+	algs := []string{}
+	for _, alg := range meta.SigningAlgs {
+		if asymmetricAlgs[alg] {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		algs = []string{"RS256"} // Required by the spec
+	}
+
+	p.meta = &meta
+	p.verify = algs
+	p.keys = newKeySet(meta.JWKSURI, p.client)
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  meta.AuthorizationEndpoint,
+			TokenURL: meta.TokenEndpoint,
+		},
+	}
+	return nil
+}
+
+func (p *oidcProvider) verifyIDToken(raw, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(kid)
+	},
+		jwt.WithValidMethods(p.verify),
+		jwt.WithIssuer(p.meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub claim")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// fillFromUserinfo copies profile claims from the userinfo endpoint. The
+// response must be about the same subject as the ID token.
+func (p *oidcProvider) fillFromUserinfo(ctx context.Context, token *oauth2.Token, claims *oidcClaims) error {
+	var info oidcClaims
+	if err := getJSON(ctx, p.client, p.meta.UserinfoEndpoint, token, &info); err != nil {
+		return fmt.Errorf("userinfo: %w", err)
+	}
+	if info.Subject != claims.Subject {
+		return errors.New("userinfo subject does not match ID token")
+	}
+
+	claims.Email = info.Email
+	claims.EmailVerified = info.EmailVerified
+	if claims.Name == "" {
+		claims.Name = info.Name
+	}
+	if claims.PreferredUsername == "" {
+		claims.PreferredUsername = info.PreferredUsername
+	}
+	if claims.Picture == "" {
+		claims.Picture = info.Picture
+	}
+	return nil
+}
+
+// getJSON fetches url (authenticated with token when non-nil) and decodes
+// the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, url string, token *oauth2.Token, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != nil {
+		token.SetAuthHeader(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,140 @@
+/**
+ * provider.go - Login Provider Registry
+ *
+ * Users can sign in through any configured OAuth 2.0 / OpenID Connect
+ * provider. Each provider turns an authorization code into the same
+ * provider-agnostic Identity, so AuthHandler does not care which one was
+ * used.
+ *
+ * Built-in providers (enabled when their client ID is set):
+ * - google: OIDC via https://accounts.google.com (GOOGLE_CLIENT_ID/SECRET)
+ * - github: OAuth 2.0 + REST API (GITHUB_CLIENT_ID/SECRET)
+ * - gitlab: OIDC via GITLAB_URL, default https://gitlab.com
+ *   (GITLAB_CLIENT_ID/SECRET)
+ *
+ * Any other OIDC provider can be added by listing its name in
+ * OIDC_PROVIDERS (e.g. "okta,keycloak") and setting, per name,
+ * OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+ * optionally OIDC_<NAME>_SCOPES.
+ *
+ * Callback URLs are <OAUTH_CALLBACK_BASE_URL>/auth/<name>/callback and must
+ * be registered with each provider. The settings are read by the config
+ * package (config.OAuthConfig.ProviderConfigs).
+ */
+
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// DefaultCallbackBaseURL is used when OAUTH_CALLBACK_BASE_URL is unset.
+const DefaultCallbackBaseURL = "http://localhost:8080"
+
+// httpTimeout bounds every request made to a provider.
+const httpTimeout = 10 * time.Second
+
+// validName restricts provider names to what is safe in URLs and env names.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+/**
+ * Identity - What a provider tells us about the signed-in user
+ */
+type Identity struct {
+	Provider      string // Provider name, e.g. "github"
+	Subject       string // Provider's stable ID for the user
+	Email         string // May be empty if the provider has none
+	EmailVerified bool   // Whether the provider verified Email
+	Username      string // Preferred handle, may be empty
+	Name          string // Display name, may be empty
+	AvatarURL     string // Profile picture, may be empty
+}
+
+/**
+ * Provider - One configured login provider
+ */
+type Provider interface {
+	// Name returns the provider's route name, e.g. "google"
+	Name() string
+	// AuthCodeURL returns the URL to send the user to. nonce is bound into
+	// the ID token by OIDC providers and ignored by plain OAuth ones.
+	AuthCodeURL(ctx context.Context, state, nonce string) (string, error)
+	// Exchange redeems an authorization code and returns the user's identity
+	Exchange(ctx context.Context, code, nonce string) (*Identity, error)
+}
+
+/**
+ * ProviderConfig - Settings for one provider
+ */
+type ProviderConfig struct {
+	Name         string   // Route name, [a-z0-9-]
+	Kind         string   // "oidc" or "github"
+	Issuer       string   // OIDC issuer URL (discovery base)
+	ClientID     string   // OAuth client ID
+	ClientSecret string   // OAuth client secret
+	Scopes       []string // Requested scopes
+	RedirectURL  string   // Our callback URL
+}
+
+/**
+ * Registry - The configured providers, by name
+ */
+type Registry struct {
+	providers map[string]Provider
+}
+
+/**
+ * NewRegistry - Builds providers from their configuration
+ *
+ * OIDC discovery happens lazily on first use, so an unreachable provider
+ * does not stop the server from starting.
+ */
+func NewRegistry(configs []ProviderConfig) (*Registry, error) {
+	client := &http.Client{Timeout: httpTimeout}
+	r := &Registry{providers: make(map[string]Provider)}
+
+	for _, cfg := range configs {
+		if !validName.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid provider name %q", cfg.Name)
+		}
+		if _, exists := r.providers[cfg.Name]; exists {
+			return nil, fmt.Errorf("provider %q configured twice", cfg.Name)
+		}
+
+		switch cfg.Kind {
+		case "oidc":
+			r.providers[cfg.Name] = newOIDCProvider(cfg, client)
+		case "github":
+			r.providers[cfg.Name] = newGitHubProvider(cfg, client)
+		default:
+			return nil, fmt.Errorf("provider %q has unknown kind %q", cfg.Name, cfg.Kind)
+		}
+	}
+
+	return r, nil
+}
+
+/**
+ * Get - Looks up a provider by route name
+ */
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+/**
+ * Names - Returns the configured provider names in alphabetical order
+ */
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
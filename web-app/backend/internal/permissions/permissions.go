@@ -0,0 +1,253 @@
+/**
+ * permissions.go - Server Permission Bitfield
+ *
+ * Permissions are stored as a BIGINT bitfield on each role. A member's
+ * effective permissions in a server are the bitwise OR of the @everyone
+ * role and every role assigned to them. Two shortcuts apply:
+ * - The server owner always has every permission
+ * - Administrator grants every permission
+ *
+ * Channels can further refine these with overwrites (see ComputeChannel).
+ *
+ * Servers can require two-factor authentication for moderation: members
+ * without 2FA enabled then lose every Moderation permission, whatever
+ * their roles or overwrites grant. The owner is exempt.
+ *
+ * Roles are also ordered by position. A member can only act on roles (and
+ * members whose highest role is) strictly below their own highest role;
+ * the owner sits above every role.
+ *
+ * This package has no database dependencies: models.RoleService loads the
+ * Inputs for a (user, server) pair and Compute does the rest.
+ */
+
+package permissions
+
+// Permission is a bitfield of server permissions
+type Permission int64
+
+// Permission bits. Values are persisted in roles.permissions, so existing
+// bits must never be renumbered.
+const (
+	ViewChannels   Permission = 1 << 0 // See channels and read their messages
+	SendMessages   Permission = 1 << 1 // Send messages in text channels
+	ManageMessages Permission = 1 << 2 // Edit or delete other members' messages
+	ManageChannels Permission = 1 << 3 // Create, rename, reorder and delete channels
+	ManageRoles    Permission = 1 << 4 // Create, edit, delete and assign lower roles
+	ManageServer   Permission = 1 << 5 // Edit server settings and manage invites
+	KickMembers    Permission = 1 << 6 // Remove members ranked below you
+	CreateInvites  Permission = 1 << 7 // Create invite links
+	Administrator  Permission = 1 << 8 // Every permission
+)
+
+// All is every defined permission bit
+const All = ViewChannels | SendMessages | ManageMessages | ManageChannels |
+	ManageRoles | ManageServer | KickMembers | CreateInvites | Administrator
+
+// ChannelScoped is every bit a channel overwrite may allow or deny
+const ChannelScoped = ViewChannels | SendMessages | ManageMessages | ManageChannels
+
+// Moderation is every bit withheld from members without 2FA in servers
+// that require it: the permissions that allow destructive actions
+const Moderation = ManageMessages | ManageChannels | ManageRoles | ManageServer |
+	KickMembers | Administrator
+
+// DirectMessage is granted to every participant of a DM or group DM
+const DirectMessage = ViewChannels | SendMessages
+
+// DefaultEveryone is granted to the @everyone role of new servers
+const DefaultEveryone = ViewChannels | SendMessages | CreateInvites
+
+// OwnerPosition ranks the owner above every role
+const OwnerPosition = int(^uint(0) >> 1)
+
+/**
+ * Has - Reports whether p includes every bit in q
+ *
+ * Administrator implies every permission.
+ *
+ * @param q Required permission(s)
+ * @return true if all of q is granted
+ */
+func (p Permission) Has(q Permission) bool {
+	if p&Administrator != 0 {
+		return true
+	}
+	return p&q == q
+}
+
+/**
+ * Valid - Reports whether p only uses defined bits
+ */
+func (p Permission) Valid() bool {
+	return p&^All == 0
+}
+
+/**
+ * Role - The parts of a role that affect permission computation
+ */
+type Role struct {
+	ID          int        // Role ID, matched against role overwrites
+	Position    int        // Higher positions outrank lower ones
+	Permissions Permission // Bits granted by this role
+}
+
+/**
+ * Inputs - Everything needed to compute a member's permissions in a server
+ */
+type Inputs struct {
+	UserID     int        // User the permissions are for, matched against member overwrites
+	IsOwner    bool       // User owns the server
+	IsMember   bool       // User is in server_members (owners always are)
+	EveryoneID int        // ID of the server's @everyone role
+	Everyone   Permission // Permissions of the server's @everyone role
+	Roles      []Role     // Roles assigned to the user
+	RequireMFA bool       // Server requires 2FA for moderation
+	MFAEnabled bool       // User has 2FA enabled
+}
+
+/**
+ * OverwriteType - Whether an overwrite targets a role or a single member
+ */
+type OverwriteType string
+
+const (
+	OverwriteRole   OverwriteType = "role"
+	OverwriteMember OverwriteType = "member"
+)
+
+/**
+ * Overwrite - Channel-level allow/deny adjustment for a role or member
+ */
+type Overwrite struct {
+	Type     OverwriteType // Role or member
+	TargetID int           // Role ID or user ID
+	Allow    Permission    // Bits granted in the channel
+	Deny     Permission    // Bits removed in the channel
+}
+
+/**
+ * Result - A member's effective permissions and rank in a server
+ */
+type Result struct {
+	Permissions     Permission // Effective permissions
+	HighestPosition int        // Position of the member's highest role (OwnerPosition for owners, 0 for @everyone only)
+	MFARestricted   bool       // Moderation bits were withheld because the user lacks 2FA
+}
+
+/**
+ * Compute - Calculates effective permissions from a member's roles
+ *
+ * Non-members get no permissions.
+ *
+ * @param in Inputs loaded for a (user, server) pair
+ * @return Effective permissions and highest role position
+ */
+func Compute(in Inputs) Result {
+	if in.IsOwner {
+		return Result{Permissions: All, HighestPosition: OwnerPosition}
+	}
+	if !in.IsMember {
+		return Result{}
+	}
+
+	result := Result{Permissions: in.Everyone}
+	for _, role := range in.Roles {
+		result.Permissions |= role.Permissions
+		if role.Position > result.HighestPosition {
+			result.HighestPosition = role.Position
+		}
+	}
+	if result.Permissions&Administrator != 0 {
+		result.Permissions = All
+	}
+	result.restrictMFA(in)
+
+	return result
+}
+
+/**
+ * restrictMFA - Withholds Moderation bits when the server requires 2FA
+ *
+ * @param in Inputs the result was computed from
+ */
+func (r *Result) restrictMFA(in Inputs) {
+	if in.RequireMFA && !in.MFAEnabled && !in.IsOwner && r.Permissions&Moderation != 0 {
+		r.Permissions &^= Moderation
+		r.MFARestricted = true
+	}
+}
+
+/**
+ * CanManage - Reports whether a member can act on something at position
+ *
+ * Used for role edits/assignments and for moderation actions against
+ * another member (compare against their HighestPosition).
+ *
+ * @param position Position of the target role or member
+ * @return true if the member outranks the target
+ */
+func (r Result) CanManage(position int) bool {
+	return r.HighestPosition > position
+}
+
+/**
+ * IsOwner - Reports whether the result was computed for the server owner
+ */
+func (r Result) IsOwner() bool {
+	return r.HighestPosition == OwnerPosition
+}
+
+/**
+ * ComputeChannel - Calculates effective permissions in a single channel
+ *
+ * Starts from the server-level result and applies the channel's
+ * overwrites in order, each layer denying before it allows:
+ * 1. The @everyone role overwrite
+ * 2. All overwrites for the member's roles, combined
+ * 3. The member's own overwrite
+ *
+ * Owners and administrators ignore overwrites. Losing View Channels
+ * removes every other channel permission too.
+ *
+ * @param in Inputs loaded for a (user, server) pair
+ * @param overwrites The channel's overwrites
+ * @return Effective channel permissions and highest role position
+ */
+func ComputeChannel(in Inputs, overwrites []Overwrite) Result {
+	result := Compute(in)
+	if in.IsOwner || !in.IsMember || result.Permissions&Administrator != 0 {
+		return result
+	}
+
+	assigned := make(map[int]bool, len(in.Roles))
+	for _, role := range in.Roles {
+		assigned[role.ID] = true
+	}
+
+	perms := result.Permissions
+	var roleAllow, roleDeny Permission
+	var member *Overwrite
+	for i, o := range overwrites {
+		switch {
+		case o.Type == OverwriteRole && o.TargetID == in.EveryoneID:
+			perms = perms&^o.Deny | o.Allow
+		case o.Type == OverwriteRole && assigned[o.TargetID]:
+			roleAllow |= o.Allow
+			roleDeny |= o.Deny
+		case o.Type == OverwriteMember && o.TargetID == in.UserID:
+			member = &overwrites[i]
+		}
+	}
+	perms = perms&^roleDeny | roleAllow
+	if member != nil {
+		perms = perms&^member.Deny | member.Allow
+	}
+
+	if perms&ViewChannels == 0 {
+		perms &^= ChannelScoped
+	}
+	result.Permissions = perms
+	result.restrictMFA(in)
+	return result
+}
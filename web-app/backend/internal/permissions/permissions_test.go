@@ -0,0 +1,304 @@
+package permissions
+
+import "testing"
+
+const everyoneID = 1
+
+// member returns Inputs for a plain member with the default @everyone role.
+func member(roles ...Role) Inputs {
+	return Inputs{
+		UserID:     10,
+		IsMember:   true,
+		EveryoneID: everyoneID,
+		Everyone:   DefaultEveryone,
+		Roles:      roles,
+	}
+}
+
+// withMFA sets the server's 2FA requirement and the user's 2FA status.
+func withMFA(in Inputs, required, enabled bool) Inputs {
+	in.RequireMFA = required
+	in.MFAEnabled = enabled
+	return in
+}
+
+func TestCompute(t *testing.T) {
+	moderator := Role{ID: 2, Position: 1, Permissions: ManageMessages | KickMembers}
+	builder := Role{ID: 3, Position: 2, Permissions: ManageChannels}
+	admin := Role{ID: 4, Position: 3, Permissions: Administrator}
+
+	tests := []struct {
+		name       string
+		in         Inputs
+		want       Permission
+		position   int
+		restricted bool
+	}{
+		{
+			name:     "owner gets every permission",
+			in:       Inputs{IsOwner: true, IsMember: true},
+			want:     All,
+			position: OwnerPosition,
+		},
+		{
+			name:     "owner without roles or membership row",
+			in:       Inputs{IsOwner: true},
+			want:     All,
+			position: OwnerPosition,
+		},
+		{
+			name: "non-member gets nothing",
+			in:   Inputs{Everyone: DefaultEveryone, Roles: []Role{admin}},
+			want: 0,
+		},
+		{
+			name: "@everyone is the base",
+			in:   member(),
+			want: DefaultEveryone,
+		},
+		{
+			name: "empty @everyone",
+			in:   Inputs{IsMember: true, Everyone: 0},
+			want: 0,
+		},
+		{
+			name:     "roles are OR'd onto @everyone",
+			in:       member(moderator, builder),
+			want:     DefaultEveryone | ManageMessages | KickMembers | ManageChannels,
+			position: 2,
+		},
+		{
+			name:     "highest position wins regardless of order",
+			in:       member(builder, moderator),
+			want:     DefaultEveryone | ManageMessages | KickMembers | ManageChannels,
+			position: 2,
+		},
+		{
+			name:     "Administrator grants every permission",
+			in:       member(admin),
+			want:     All,
+			position: 3,
+		},
+		{
+			name: "Administrator on @everyone",
+			in:   Inputs{IsMember: true, Everyone: Administrator},
+			want: All,
+		},
+		{
+			name:       "2FA requirement strips Moderation without 2FA",
+			in:         withMFA(member(moderator, builder), true, false),
+			want:       DefaultEveryone,
+			position:   2,
+			restricted: true,
+		},
+		{
+			name:       "2FA requirement strips Administrator's Moderation bits",
+			in:         withMFA(member(admin), true, false),
+			want:       All &^ Moderation,
+			position:   3,
+			restricted: true,
+		},
+		{
+			name:     "2FA requirement is met with 2FA enabled",
+			in:       withMFA(member(moderator), true, true),
+			want:     DefaultEveryone | ManageMessages | KickMembers,
+			position: 1,
+		},
+		{
+			name: "2FA requirement leaves members without Moderation bits alone",
+			in:   withMFA(member(), true, false),
+			want: DefaultEveryone,
+		},
+		{
+			name:     "owner is exempt from the 2FA requirement",
+			in:       withMFA(Inputs{IsOwner: true}, true, false),
+			want:     All,
+			position: OwnerPosition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.in)
+			if got.Permissions != tt.want {
+				t.Errorf("Permissions = %b, want %b", got.Permissions, tt.want)
+			}
+			if got.HighestPosition != tt.position {
+				t.Errorf("HighestPosition = %d, want %d", got.HighestPosition, tt.position)
+			}
+			if got.MFARestricted != tt.restricted {
+				t.Errorf("MFARestricted = %v, want %v", got.MFARestricted, tt.restricted)
+			}
+		})
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   Result
+		position int
+		want     bool
+	}{
+		{"higher role outranks lower", Result{HighestPosition: 3}, 2, true},
+		{"equal position cannot manage", Result{HighestPosition: 2}, 2, false},
+		{"lower role cannot manage higher", Result{HighestPosition: 1}, 2, false},
+		{"@everyone only cannot manage @everyone", Result{}, 0, false},
+		{"owner outranks every role", Compute(Inputs{IsOwner: true}), OwnerPosition - 1, true},
+		{"owner cannot manage the owner", Compute(Inputs{IsOwner: true}), OwnerPosition, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.CanManage(tt.position); got != tt.want {
+				t.Fatalf("CanManage(%d) = %v, want %v", tt.position, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Permission
+		q    Permission
+		want bool
+	}{
+		{"single bit", SendMessages, SendMessages, true},
+		{"missing bit", SendMessages, ManageMessages, false},
+		{"needs every requested bit", SendMessages, SendMessages | ManageMessages, false},
+		{"Administrator implies everything", Administrator, ManageServer | KickMembers, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.Has(tt.q); got != tt.want {
+				t.Fatalf("Has = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeChannel(t *testing.T) {
+	moderator := Role{ID: 2, Position: 1, Permissions: ManageMessages}
+	muted := Role{ID: 3, Position: 2}
+	admin := Role{ID: 4, Position: 3, Permissions: Administrator}
+
+	everyone := func(allow, deny Permission) Overwrite {
+		return Overwrite{Type: OverwriteRole, TargetID: everyoneID, Allow: allow, Deny: deny}
+	}
+	role := func(r Role, allow, deny Permission) Overwrite {
+		return Overwrite{Type: OverwriteRole, TargetID: r.ID, Allow: allow, Deny: deny}
+	}
+	self := func(allow, deny Permission) Overwrite {
+		return Overwrite{Type: OverwriteMember, TargetID: 10, Allow: allow, Deny: deny}
+	}
+
+	tests := []struct {
+		name       string
+		in         Inputs
+		overwrites []Overwrite
+		want       Permission
+	}{
+		{
+			name: "no overwrites keeps server permissions",
+			in:   member(moderator),
+			want: DefaultEveryone | ManageMessages,
+		},
+		{
+			name:       "@everyone overwrite denies",
+			in:         member(),
+			overwrites: []Overwrite{everyone(0, SendMessages)},
+			want:       DefaultEveryone &^ SendMessages,
+		},
+		{
+			name:       "@everyone overwrite allows",
+			in:         member(),
+			overwrites: []Overwrite{everyone(ManageMessages, 0)},
+			want:       DefaultEveryone | ManageMessages,
+		},
+		{
+			name:       "role overwrite applies after @everyone",
+			in:         member(moderator),
+			overwrites: []Overwrite{role(moderator, SendMessages, 0), everyone(0, SendMessages)},
+			want:       DefaultEveryone | ManageMessages,
+		},
+		{
+			name:       "role allows beat role denies",
+			in:         member(moderator, muted),
+			overwrites: []Overwrite{role(muted, 0, SendMessages), role(moderator, SendMessages, 0)},
+			want:       DefaultEveryone | ManageMessages,
+		},
+		{
+			name:       "role denies are combined",
+			in:         member(moderator, muted),
+			overwrites: []Overwrite{role(muted, 0, SendMessages), role(moderator, 0, ManageMessages)},
+			want:       DefaultEveryone &^ SendMessages,
+		},
+		{
+			name:       "overwrites for unassigned roles are ignored",
+			in:         member(),
+			overwrites: []Overwrite{role(muted, 0, SendMessages)},
+			want:       DefaultEveryone,
+		},
+		{
+			name:       "member overwrite applies last",
+			in:         member(muted),
+			overwrites: []Overwrite{self(SendMessages, 0), role(muted, 0, SendMessages)},
+			want:       DefaultEveryone,
+		},
+		{
+			name:       "member overwrite for someone else is ignored",
+			in:         member(),
+			overwrites: []Overwrite{{Type: OverwriteMember, TargetID: 11, Deny: SendMessages}},
+			want:       DefaultEveryone,
+		},
+		{
+			name:       "member overwrite target is not a role ID",
+			in:         member(),
+			overwrites: []Overwrite{{Type: OverwriteRole, TargetID: 10, Deny: SendMessages}},
+			want:       DefaultEveryone,
+		},
+		{
+			name:       "losing View Channels clears channel-scoped bits",
+			in:         member(moderator),
+			overwrites: []Overwrite{everyone(0, ViewChannels)},
+			want:       CreateInvites,
+		},
+		{
+			name:       "role overwrite can restore View Channels",
+			in:         member(moderator),
+			overwrites: []Overwrite{everyone(0, ViewChannels), role(moderator, ViewChannels, 0)},
+			want:       DefaultEveryone | ManageMessages,
+		},
+		{
+			name:       "Administrator bypasses overwrites",
+			in:         member(admin),
+			overwrites: []Overwrite{everyone(0, ViewChannels), role(admin, 0, All), self(0, All)},
+			want:       All,
+		},
+		{
+			name:       "owner bypasses overwrites",
+			in:         Inputs{UserID: 10, IsOwner: true, IsMember: true, EveryoneID: everyoneID},
+			overwrites: []Overwrite{everyone(0, ViewChannels), self(0, All)},
+			want:       All,
+		},
+		{
+			name:       "non-member gets nothing despite allows",
+			in:         Inputs{UserID: 10, EveryoneID: everyoneID},
+			overwrites: []Overwrite{everyone(ViewChannels, 0), self(ChannelScoped, 0)},
+			want:       0,
+		},
+		{
+			name:       "2FA requirement strips Moderation bits granted by overwrites",
+			in:         withMFA(member(), true, false),
+			overwrites: []Overwrite{self(ManageMessages, 0)},
+			want:       DefaultEveryone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeChannel(tt.in, tt.overwrites)
+			if got.Permissions != tt.want {
+				t.Fatalf("Permissions = %b, want %b", got.Permissions, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+/**
+ * tokens.go - Access Token Signing and Verification
+ *
+ * Service is the single place access tokens (JWTs) are issued and checked:
+ * AuthHandler signs with it, and JWTMiddleware and the WebSocket gateway
+ * verify with it (through middleware.Authenticator).
+ *
+ * Signing:
+ * - HS256 with JWT_SECRET when no private key is configured
+ * - RS256 or EdDSA with the PEM key in JWT_PRIVATE_KEY_FILE; the algorithm
+ *   follows the key type (RSA or Ed25519)
+ * - Asymmetric tokens carry a "kid" header: the key's RFC 7638 thumbprint
+ *
+ * Verification accepts every configured key, looked up by kid:
+ * - The signing key
+ * - Public keys listed in JWT_VERIFICATION_KEY_FILES, e.g. the previous key
+ *   while its tokens expire, or the next key before it is rolled out
+ * - JWT_SECRET (tokens without kid) even when signing asymmetrically, so
+ *   switching away from HS256 does not sign everyone out
+ *
+ * Public verification keys are published as a JWKS (see JWKS) so other
+ * services can verify tokens without sharing a secret.
+ */
+
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultTTL is the default access token lifetime (JWT_EXPIRATION).
+const DefaultTTL = 15 * time.Minute
+
+// minRSABits is the smallest RSA key accepted for signing or verification.
+const minRSABits = 2048
+
+/**
+ * Claims - Claims carried by every access token
+ *
+ * The standard "jti" claim (RegisteredClaims.ID) holds the session ID.
+ */
+type Claims struct {
+	UserID               int    `json:"user_id"`  // Database user ID
+	Email                string `json:"email"`    // User email address
+	Username             string `json:"username"` // User display name
+	jwt.RegisteredClaims        // Standard JWT claims (exp, iat, jti, etc.)
+}
+
+/**
+ * SessionID - Returns the session the token was issued for (the jti claim)
+ */
+func (c *Claims) SessionID() string {
+	return c.ID
+}
+
+/**
+ * Config - Token lifetime and key material
+ *
+ * Either Secret or PrivateKeyPEM must be set. VerificationKeyPEMs are
+ * extra PEM public keys accepted for verification only. Built from the
+ * server settings by config.JWTConfig.TokenConfig.
+ */
+type Config struct {
+	TTL                 time.Duration
+	Secret              []byte
+	PrivateKeyPEM       []byte
+	VerificationKeyPEMs [][]byte
+}
+
+// key is one verification key and the only algorithm it may be used with.
+type key struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+/**
+ * Service - Issues and verifies access tokens
+ */
+type Service struct {
+	ttl        time.Duration
+	method     jwt.SigningMethod
+	signingKey interface{}    // []byte or crypto.Signer
+	keyID      string         // Empty for HS256
+	keys       map[string]key // kid -> key; "" is the HMAC secret
+}
+
+/**
+ * NewService - Builds a Service from parsed configuration
+ *
+ * @return error if keys are missing, malformed or too weak
+ */
+func NewService(cfg Config) (*Service, error) {
+	if cfg.TTL <= 0 {
+		return nil, errors.New("token TTL must be positive")
+	}
+
+	s := &Service{ttl: cfg.TTL, keys: make(map[string]key)}
+
+	if len(cfg.Secret) > 0 {
+		s.keys[""] = key{method: jwt.SigningMethodHS256, public: cfg.Secret}
+	}
+
+	if len(cfg.PrivateKeyPEM) > 0 {
+		private, err := parsePrivateKey(cfg.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		kid, err := s.addPublicKey(private.Public())
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		s.signingKey = private
+		s.keyID = kid
+		s.method = s.keys[kid].method
+	} else if len(cfg.Secret) > 0 {
+		s.signingKey = cfg.Secret
+		s.method = jwt.SigningMethodHS256
+	} else {
+		return nil, errors.New("either JWT_SECRET or JWT_PRIVATE_KEY_FILE must be set")
+	}
+
+	for i, data := range cfg.VerificationKeyPEMs {
+		public, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %d: %w", i+1, err)
+		}
+		if _, err := s.addPublicKey(public); err != nil {
+			return nil, fmt.Errorf("verification key %d: %w", i+1, err)
+		}
+	}
+
+	return s, nil
+}
+
+/**
+ * TTL - Returns how long issued tokens are valid
+ */
+func (s *Service) TTL() time.Duration {
+	return s.ttl
+}
+
+/**
+ * Issue - Signs an access token for a user's session
+ *
+ * @param userID Database user ID
+ * @param email User email address
+ * @param username User display name
+ * @param sessionID Session the token belongs to (jti)
+ * @return Signed token string
+ */
+func (s *Service) Issue(userID int, email, username, sessionID string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:   userID,
+		Email:    email,
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.keyID != "" {
+		token.Header["kid"] = s.keyID
+	}
+
+	signed, err := token.SignedString(s.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signed, nil
+}
+
+/**
+ * Parse - Verifies a token's signature and expiry and returns its claims
+ *
+ * The key is chosen by the "kid" header and must match the token's
+ * algorithm, so an RSA public key can never be used as an HMAC secret.
+ */
+func (s *Service) Parse(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return k.public, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("failed to parse token claims")
+	}
+	return claims, nil
+}
+
+/**
+ * JWK - One public key in JSON Web Key format (RFC 7517)
+ */
+type JWK struct {
+	KeyType   string `json:"kty"`           // "RSA" or "OKP"
+	KeyID     string `json:"kid"`           // Matches the token's kid header
+	Use       string `json:"use"`           // Always "sig"
+	Algorithm string `json:"alg"`           // "RS256" or "EdDSA"
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // "Ed25519"
+	X         string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+/**
+ * JWKSet - Response body of the JWKS endpoint
+ */
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+/**
+ * JWKS - Returns every asymmetric verification key
+ *
+ * The HMAC secret is never included.
+ */
+func (s *Service) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for kid, k := range s.keys {
+		if jwk, ok := toJWK(k.public); ok {
+			jwk.KeyID = kid
+			jwk.Algorithm = k.method.Alg()
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// addPublicKey registers an RSA or Ed25519 public key under its thumbprint.
+func (s *Service) addPublicKey(public crypto.PublicKey) (string, error) {
+	var method jwt.SigningMethod
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return "", fmt.Errorf("unsupported key type %T (use RSA or Ed25519)", public)
+	}
+
+	jwk, _ := toJWK(public)
+	kid := thumbprint(jwk)
+	s.keys[kid] = key{method: method, public: public}
+	return kid, nil
+}
+
+func toJWK(public crypto.PublicKey) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			Use:     "sig",
+			N:       enc.EncodeToString(pub.N.Bytes()),
+			E:       enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Use: "sig", Curve: "Ed25519", X: enc.EncodeToString(pub)}, true
+	}
+	return JWK{}, false
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as kid.
+func thumbprint(jwk JWK) string {
+	// Required members only, in lexicographic order
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("expected a PKCS#8 or PKCS#1 private key")
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("expected a PKIX or PKCS#1 public key")
+}
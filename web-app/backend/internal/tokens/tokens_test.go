@@ -0,0 +1,203 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var secret = []byte("test-secret-at-least-32-bytes-long!!")
+
+func newEd25519(t *testing.T) (privatePEM, publicPEM []byte, signer crypto.Signer) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeys(t, private, public)
+}
+
+func newRSA(t *testing.T) (privatePEM, publicPEM []byte, signer crypto.Signer) {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeys(t, private, &private.PublicKey)
+}
+
+func encodeKeys(t *testing.T, private crypto.Signer, public crypto.PublicKey) ([]byte, []byte, crypto.Signer) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		private
+}
+
+func newService(t *testing.T, cfg Config) *Service {
+	t.Helper()
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultTTL
+	}
+	s, err := NewService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func issue(t *testing.T, s *Service) string {
+	t.Helper()
+	token, err := s.Issue(1, "user@example.com", "user", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// forge signs valid claims with an arbitrary algorithm, kid and key.
+func forge(t *testing.T, method jwt.SigningMethod, kid string, signingKey interface{}) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(method, &Claims{
+		UserID:   1,
+		Username: "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "session-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestParseRejectsAlgorithmConfusion(t *testing.T) {
+	rsaPrivate, rsaPublic, rsaSigner := newRSA(t)
+	_, _, edSigner := newEd25519(t)
+	s := newService(t, Config{Secret: secret, PrivateKeyPEM: rsaPrivate})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 keyed with the RSA public key PEM", forge(t, jwt.SigningMethodHS256, s.keyID, rsaPublic)},
+		{"HS256 with the RSA key's kid", forge(t, jwt.SigningMethodHS256, s.keyID, secret)},
+		{"RS256 without kid uses the HMAC slot", forge(t, jwt.SigningMethodRS256, "", rsaSigner)},
+		{"EdDSA with the RSA key's kid", forge(t, jwt.SigningMethodEdDSA, s.keyID, edSigner)},
+		{"alg none", forge(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Parse(tt.token); err == nil {
+				t.Fatal("Parse accepted a token with the wrong algorithm")
+			}
+		})
+	}
+
+	// The legitimate tokens for both slots still verify
+	if _, err := s.Parse(issue(t, s)); err != nil {
+		t.Fatalf("RS256 token: %v", err)
+	}
+	if _, err := s.Parse(forge(t, jwt.SigningMethodHS256, "", secret)); err != nil {
+		t.Fatalf("HS256 token without kid: %v", err)
+	}
+}
+
+func TestParseRejectsUnknownKeyID(t *testing.T) {
+	private, _, _ := newEd25519(t)
+	otherPrivate, _, otherSigner := newEd25519(t)
+	s := newService(t, Config{PrivateKeyPEM: private})
+	other := newService(t, Config{PrivateKeyPEM: otherPrivate})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"kid of a key the service does not know", issue(t, other)},
+		{"made-up kid", forge(t, jwt.SigningMethodEdDSA, "no-such-key", otherSigner)},
+		{"no kid and no HMAC secret", forge(t, jwt.SigningMethodEdDSA, "", otherSigner)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Parse(tt.token)
+			if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+				t.Fatalf("Parse error = %v, want unknown signing key", err)
+			}
+		})
+	}
+}
+
+func TestParseWithRotatedKeys(t *testing.T) {
+	oldPrivate, oldPublic, _ := newEd25519(t)
+	newPrivate, newPublic, _ := newRSA(t)
+
+	tests := []struct {
+		name     string
+		issuer   Config
+		verifier Config
+		wantErr  bool
+	}{
+		{
+			name:     "old key still listed after rotation",
+			issuer:   Config{PrivateKeyPEM: oldPrivate},
+			verifier: Config{PrivateKeyPEM: newPrivate, VerificationKeyPEMs: [][]byte{oldPublic}},
+		},
+		{
+			name:     "old key dropped after rotation",
+			issuer:   Config{PrivateKeyPEM: oldPrivate},
+			verifier: Config{PrivateKeyPEM: newPrivate},
+			wantErr:  true,
+		},
+		{
+			name:     "next key listed before rollout",
+			issuer:   Config{PrivateKeyPEM: newPrivate},
+			verifier: Config{PrivateKeyPEM: oldPrivate, VerificationKeyPEMs: [][]byte{newPublic}},
+		},
+		{
+			name:     "secret kept after switching to asymmetric signing",
+			issuer:   Config{Secret: secret},
+			verifier: Config{Secret: secret, PrivateKeyPEM: newPrivate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := issue(t, newService(t, tt.issuer))
+
+			claims, err := newService(t, tt.verifier).Parse(token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Parse accepted a token signed with a removed key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims.UserID != 1 || claims.SessionID() != "session-1" {
+				t.Fatalf("claims = %+v", claims)
+			}
+		})
+	}
+}
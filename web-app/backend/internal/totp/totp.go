@@ -0,0 +1,129 @@
+/**
+ * totp.go - Time-Based One-Time Passwords (RFC 6238)
+ *
+ * Codes are what authenticator apps (Google Authenticator, 1Password,
+ * Authy, ...) show: 6 digits from HMAC-SHA1 over the number of 30 second
+ * steps since the Unix epoch, using a shared secret.
+ *
+ * Validation accepts the previous and next step as well to allow for
+ * clock drift. It returns the matched step so callers can reject a code
+ * that was already used (each step should be accepted at most once).
+ */
+
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Period = 30 * time.Second // Length of one time step
+	Digits = 6                // Code length
+	Skew   = 1                // Steps accepted before and after the current one
+
+	secretSize = 20 // Bytes; the RFC 4226 recommended HMAC-SHA1 key length
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+/**
+ * GenerateSecret - Creates a random shared secret
+ *
+ * @return Base32 secret (no padding), as entered into authenticator apps
+ */
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+/**
+ * URI - Builds the otpauth:// URI authenticator apps import (usually as a QR code)
+ *
+ * @param secret Base32 secret from GenerateSecret
+ * @param issuer Service name shown in the app
+ * @param account Account label, e.g. the user's email
+ * @return otpauth://totp/ URI
+ */
+func URI(secret, issuer, account string) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period.Seconds()))},
+	}
+	label := url.PathEscape(issuer + ":" + account)
+	// Some apps show "+" literally, so spaces are encoded as %20
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+/**
+ * Validate - Checks a code against the steps around t
+ *
+ * Spaces in code are ignored.
+ *
+ * @param secret Base32 secret
+ * @param code Code entered by the user
+ * @param t Time to check against, normally time.Now()
+ * @return The time step the code belongs to and whether it matched
+ */
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.Unix() / int64(Period.Seconds())
+	for step := current - Skew; step <= current+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+/**
+ * Code - Returns the code for secret at time t
+ */
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return generate(key, t.Unix()/int64(Period.Seconds())), nil
+}
+
+// generate computes the RFC 4226 HOTP value for counter step.
+func generate(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
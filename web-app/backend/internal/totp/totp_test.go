@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 appendix B SHA-1 key "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B SHA-1 vectors, truncated from 8 to Digits digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		step, ok := Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("Validate rejected %s at %d", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("Validate at %d returned step %d, want %d", tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	issued := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same step", 0, true},
+		{"one step later", Period, true},
+		{"one step earlier", -Period, true},
+		{"two steps later", 2 * Period, false},
+		{"two steps earlier", -2 * Period, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, code, issued.Add(tt.offset))
+			if ok != tt.ok {
+				t.Fatalf("Validate ok = %v, want %v", ok, tt.ok)
+			}
+			// The matched step is the code's, not the validation time's
+			if ok && step != issued.Unix()/30 {
+				t.Fatalf("Validate returned step %d, want %d", step, issued.Unix()/30)
+			}
+		})
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"spaces are ignored", rfcSecret, "050 471", true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", true},
+		{"wrong code", rfcSecret, "050472", false},
+		{"too short", rfcSecret, "50471", false},
+		{"eight digit code", rfcSecret, "14050471", false},
+		{"invalid secret", "not base32!", "050471", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, at); ok != tt.ok {
+				t.Fatalf("Validate ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS server_members;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS channels;
+DROP TABLE IF EXISTS servers;
+DROP TABLE IF EXISTS users;
@@ -52,7 +52,7 @@ CREATE TABLE IF NOT EXISTS server_members (
 );
 
 -- Create indexes for better performance
-CREATE INDEX idx_messages_channel_id ON messages(channel_id);
-CREATE INDEX idx_messages_user_id ON messages(user_id);
-CREATE INDEX idx_channels_server_id ON channels(server_id);
-CREATE INDEX idx_server_members_user_id ON server_members(user_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_messages_channel_id ON messages(channel_id);
+CREATE INDEX IF NOT EXISTS idx_messages_user_id ON messages(user_id);
+CREATE INDEX IF NOT EXISTS idx_channels_server_id ON channels(server_id);
+CREATE INDEX IF NOT EXISTS idx_server_members_user_id ON server_members(user_id);
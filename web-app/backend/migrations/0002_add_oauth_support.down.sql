@@ -0,0 +1,8 @@
+-- Accounts without a password cannot be kept once it is required again
+DELETE FROM users WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_provider;
+DROP INDEX IF EXISTS idx_users_google_id;
+ALTER TABLE users DROP COLUMN IF EXISTS provider;
+ALTER TABLE users DROP COLUMN IF EXISTS google_id;
@@ -5,4 +5,4 @@ ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
 
 -- Add index for OAuth lookups
 CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
-CREATE INDEX IF NOT EXISTS idx_users_provider ON users(provider);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_users_provider ON users(provider);
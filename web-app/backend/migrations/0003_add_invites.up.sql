@@ -0,0 +1,14 @@
+-- Create invites table
+-- max_uses and expires_at are NULL for unlimited / never-expiring invites
+CREATE TABLE IF NOT EXISTS invites (
+    code VARCHAR(16) PRIMARY KEY,
+    server_id INTEGER NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+    inviter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    max_uses INTEGER,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add index for listing a server's invites
+CREATE INDEX IF NOT EXISTS idx_invites_server_id ON invites(server_id);
@@ -0,0 +1,13 @@
+-- Bring back the free-form role column from the Admin and Moderator roles
+ALTER TABLE server_members ADD COLUMN IF NOT EXISTS role VARCHAR(50) DEFAULT 'member';
+
+UPDATE server_members sm SET role = 'moderator'
+FROM member_roles mr JOIN roles r ON r.id = mr.role_id
+WHERE mr.server_id = sm.server_id AND mr.user_id = sm.user_id AND r.name = 'Moderator';
+
+UPDATE server_members sm SET role = 'admin'
+FROM member_roles mr JOIN roles r ON r.id = mr.role_id
+WHERE mr.server_id = sm.server_id AND mr.user_id = sm.user_id AND r.name = 'Admin';
+
+DROP TABLE IF EXISTS member_roles;
+DROP TABLE IF EXISTS roles;
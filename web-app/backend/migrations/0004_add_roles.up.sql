@@ -0,0 +1,60 @@
+-- Create roles table
+-- permissions is a bitfield (see internal/permissions); every server has
+-- exactly one is_default role (@everyone) at position 0
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    server_id INTEGER NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    color INTEGER NOT NULL DEFAULT 0,
+    position INTEGER NOT NULL DEFAULT 0,
+    permissions BIGINT NOT NULL DEFAULT 0,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create member_roles table (many-to-many member <-> role)
+CREATE TABLE IF NOT EXISTS member_roles (
+    server_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (server_id, user_id, role_id),
+    FOREIGN KEY (server_id, user_id) REFERENCES server_members(server_id, user_id) ON DELETE CASCADE
+);
+
+-- Add indexes for role lookups
+CREATE INDEX IF NOT EXISTS idx_roles_server_id ON roles(server_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_server_default ON roles(server_id) WHERE is_default;
+CREATE INDEX IF NOT EXISTS idx_member_roles_role_id ON member_roles(role_id);
+
+-- Backfill @everyone for existing servers (View Channels | Send Messages | Create Invites)
+INSERT INTO roles (server_id, name, position, permissions, is_default)
+SELECT id, '@everyone', 0, 131, TRUE FROM servers
+WHERE NOT EXISTS (SELECT 1 FROM roles r WHERE r.server_id = servers.id AND r.is_default);
+
+-- Convert free-form server_members.role values into real roles
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'server_members' AND column_name = 'role') THEN
+        INSERT INTO roles (server_id, name, position, permissions)
+        SELECT DISTINCT server_id, 'Admin', 2, 256 FROM server_members WHERE role = 'admin';
+
+        INSERT INTO roles (server_id, name, position, permissions)
+        SELECT DISTINCT server_id, 'Moderator', 1, 68 FROM server_members WHERE role = 'moderator';
+
+        INSERT INTO member_roles (server_id, user_id, role_id)
+        SELECT sm.server_id, sm.user_id, r.id
+        FROM server_members sm
+        JOIN roles r ON r.server_id = sm.server_id
+         AND ((sm.role = 'admin' AND r.name = 'Admin') OR (sm.role = 'moderator' AND r.name = 'Moderator'))
+        ON CONFLICT DO NOTHING;
+    END IF;
+END $$;
+
+-- Make sure every owner is a member, then drop the free-form role column
+INSERT INTO server_members (server_id, user_id)
+SELECT id, owner_id FROM servers WHERE owner_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE server_members DROP COLUMN IF EXISTS role;
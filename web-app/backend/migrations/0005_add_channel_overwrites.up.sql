@@ -0,0 +1,17 @@
+-- Create channel_overwrites table
+-- Each row targets exactly one role or one member; allow and deny are
+-- permission bitfields (see internal/permissions) applied on top of the
+-- member's server-level permissions
+CREATE TABLE IF NOT EXISTS channel_overwrites (
+    id SERIAL PRIMARY KEY,
+    channel_id INTEGER NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    role_id INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    allow BIGINT NOT NULL DEFAULT 0,
+    deny BIGINT NOT NULL DEFAULT 0,
+    CHECK ((role_id IS NULL) <> (user_id IS NULL))
+);
+
+-- One overwrite per (channel, role) and per (channel, member)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_overwrites_role ON channel_overwrites(channel_id, role_id) WHERE role_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_overwrites_user ON channel_overwrites(channel_id, user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS channel_recipients;
+
+-- DM channels have no server and cannot exist without these columns
+DELETE FROM channels WHERE server_id IS NULL;
+ALTER TABLE channels DROP COLUMN IF EXISTS dm_key;
+ALTER TABLE channels DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,16 @@
+-- Direct messages reuse the channels table with type 'dm' or 'group_dm'
+-- and no server_id. owner_id is the group DM owner; dm_key is
+-- "<low user id>:<high user id>" for 1:1 DMs so each pair has one channel
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS owner_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS dm_key VARCHAR(32) UNIQUE;
+
+-- Create channel_recipients table (participants of DM channels)
+CREATE TABLE IF NOT EXISTS channel_recipients (
+    channel_id INTEGER NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+-- Add index for listing a user's DMs
+CREATE INDEX IF NOT EXISTS idx_channel_recipients_user_id ON channel_recipients(user_id);
@@ -0,0 +1,19 @@
+-- Create sessions table
+-- A session is one sign-in on one device. Its id is the "jti" claim of
+-- every access token issued for it and the family_id of its refresh
+-- tokens, so revoking it kills both. expires_at follows the newest
+-- refresh token; last_seen_at is bumped at most once per cache interval.
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_name VARCHAR(100) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+-- Add index for listing a user's sessions
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
@@ -0,0 +1,14 @@
+-- Only Google accounts fit the old google_id column
+DROP INDEX IF EXISTS idx_users_provider_user_id;
+UPDATE users SET provider_user_id = NULL WHERE provider <> 'google';
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'users' AND column_name = 'provider_user_id') THEN
+        ALTER TABLE users RENAME COLUMN provider_user_id TO google_id;
+    END IF;
+END $$;
+
+ALTER TABLE users ADD CONSTRAINT users_google_id_key UNIQUE (google_id);
+CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
@@ -0,0 +1,16 @@
+-- Generalize users.google_id to any login provider
+-- users.provider names the provider ('google', 'github', an OIDC provider
+-- or 'local') and provider_user_id is that provider's stable ID for the user
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'users' AND column_name = 'google_id') THEN
+        ALTER TABLE users RENAME COLUMN google_id TO provider_user_id;
+    END IF;
+END $$;
+
+-- provider_user_id is only unique per provider
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_google_id_key;
+DROP INDEX IF EXISTS idx_users_google_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_provider_user_id
+    ON users(provider, provider_user_id) WHERE provider_user_id IS NOT NULL;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS pending_identity_links;
+
+-- Move each user's identity for their sign-up provider back onto users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS provider_user_id VARCHAR(255);
+
+UPDATE users u SET provider_user_id = i.subject
+FROM user_identities i
+WHERE i.user_id = u.id AND i.provider = u.provider;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_provider_user_id
+    ON users(provider, provider_user_id) WHERE provider_user_id IS NOT NULL;
+
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,45 @@
+-- Create user_identities table (login provider accounts linked to a user)
+-- A user can sign in with any linked identity or, if password_hash is set,
+-- with email and password. Existing provider logins are moved over from
+-- users.provider_user_id; users.provider keeps how the account was created.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+-- Add index for listing a user's identities
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'users' AND column_name = 'provider_user_id') THEN
+        INSERT INTO user_identities (user_id, provider, subject, email)
+        SELECT id, provider, provider_user_id, email FROM users
+        WHERE provider_user_id IS NOT NULL
+        ON CONFLICT DO NOTHING;
+
+        DROP INDEX IF EXISTS idx_users_provider_user_id;
+        ALTER TABLE users DROP COLUMN provider_user_id;
+    END IF;
+END $$;
+
+-- Create pending_identity_links table
+-- Rows with a subject are provider accounts whose verified email matched an
+-- existing user; the user must confirm while signed in before they are
+-- linked. Rows without one are link requests started by a signed-in user
+-- and completed by the provider callback. Only token hashes are stored.
+CREATE TABLE IF NOT EXISTS pending_identity_links (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255),
+    email VARCHAR(255),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+-- Rewritten usernames are not restored; display names are dropped
+ALTER TABLE users ALTER COLUMN username TYPE VARCHAR(50);
+ALTER TABLE users DROP COLUMN IF EXISTS username_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
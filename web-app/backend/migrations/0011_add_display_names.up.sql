@@ -0,0 +1,39 @@
+-- Separate display names from unique handles
+-- users.username becomes a normalized handle: 2-32 characters of a-z, 0-9,
+-- "_" and "." (no consecutive periods). Free-form names go in
+-- display_name; NULL means clients show the username.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100);
+
+-- Last handle change, for rate limiting changes
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username_changed_at TIMESTAMP;
+
+-- Rewrite existing usernames that are not valid handles. The old value is
+-- kept as the display name; collisions get a random 4-digit suffix.
+DO $$
+DECLARE
+    r RECORD;
+    base TEXT;
+    candidate TEXT;
+BEGIN
+    FOR r IN SELECT id, username FROM users
+             WHERE username !~ '^[a-z0-9_.]{2,32}$' OR username LIKE '%..%'
+             ORDER BY id LOOP
+        base := regexp_replace(lower(r.username), '[[:space:]-]+', '_', 'g');
+        base := regexp_replace(base, '[^a-z0-9_.]', '', 'g');
+        base := regexp_replace(base, '\.{2,}', '.', 'g');
+        base := left(base, 27);
+        IF length(base) < 2 THEN
+            base := 'user';
+        END IF;
+
+        candidate := base;
+        WHILE EXISTS (SELECT 1 FROM users WHERE username = candidate AND id <> r.id) LOOP
+            candidate := base || '_' || lpad(floor(random() * 10000)::text, 4, '0');
+        END LOOP;
+
+        UPDATE users SET display_name = COALESCE(display_name, r.username), username = candidate
+        WHERE id = r.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE users ALTER COLUMN username TYPE VARCHAR(32);
@@ -0,0 +1,32 @@
+-- Add TOTP two-factor authentication to users
+-- totp_secret is set when enrollment starts; 2FA is on once the user
+-- confirms a code and totp_enabled_at is set. totp_last_step is the time
+-- step of the last accepted code, so each code works only once.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+-- Create recovery_codes table (single-use 2FA fallbacks, stored as SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Create mfa_challenges table
+-- A sign-in by a user with 2FA stops here until a code is given; the
+-- ticket (stored hashed) carries what is needed to finish the sign-in.
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    ticket_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_name VARCHAR(100) NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Servers can require moderators to have 2FA enabled
+ALTER TABLE servers ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,10 @@
+-- Create auth_codes table
+-- Provider sign-ins redirect to the frontend with a short-lived,
+-- single-use code instead of tokens; the frontend exchanges it with
+-- POST /auth/exchange. Only code hashes are stored.
+CREATE TABLE IF NOT EXISTS auth_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
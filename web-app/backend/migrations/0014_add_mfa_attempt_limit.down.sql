@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_attempts_reset_at;
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_attempts;
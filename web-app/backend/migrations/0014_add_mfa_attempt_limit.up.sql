@@ -0,0 +1,6 @@
+-- Limit second-factor guesses on authenticated 2FA endpoints
+-- (disabling 2FA, regenerating recovery codes). Sign-in challenges have
+-- their own per-ticket limit; these columns count attempts per user
+-- within a window that starts at the first failure.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_attempts_reset_at TIMESTAMP;
@@ -0,0 +1,24 @@
+/**
+ * migrations.go - Embedded SQL Schema Migrations
+ *
+ * Every schema change is a pair of files named
+ * <version>_<name>.up.sql and <version>_<name>.down.sql, applied in
+ * version order by the migrate package. They are compiled into the
+ * server binary, so a deployed server always carries the schema it needs.
+ *
+ * Up migrations must be safe to run against a database that already has
+ * their changes (IF NOT EXISTS, guarded DO blocks): databases created by
+ * the old docker-compose init scripts have no schema_migrations table and
+ * are brought under version control by running every migration once.
+ *
+ * Never edit a migration that has been released; add a new one instead.
+ */
+
+package migrations
+
+import "embed"
+
+// FS holds the *.up.sql and *.down.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -1,37 +1,135 @@
 package pkg
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"sort"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/user/web-app/internal/config"
 )
 
-func ConnectDatabase() (*sql.DB, error) {
-	host := "localhost"
-	port := os.Getenv("POSTGRES_PORT")
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
-
-	if port == "" {
-		port = "5432"
-	}
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+// Delays between connection attempts while PostgreSQL is starting
+const (
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 5 * time.Second
+)
 
-	db, err := sql.Open("postgres", psqlInfo)
+/**
+ * ConnectDatabase - Opens the connection pool and waits for PostgreSQL
+ *
+ * The first connection is retried with exponential backoff for up to
+ * cfg.StartupTimeout, so the server can start alongside the database
+ * (e.g. in docker compose). Errors reported by PostgreSQL itself, such as
+ * a wrong password, fail immediately.
+ *
+ * @param cfg Connection, TLS and pool settings
+ * @return Connection pool ready for use
+ */
+func ConnectDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connectionString(cfg))
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := waitForDatabase(db, cfg.StartupTimeout); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	log.Println("Successfully connected to database")
 	return db, nil
+}
+
+// waitForDatabase pings db until it answers, a permanent error occurs or
+// timeout passes. A zero timeout means a single attempt.
+func waitForDatabase(db *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return db.Ping()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var lastErr error
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil && lastErr != nil {
+			break // The attempt was cut short; report why the last full one failed
+		}
+		lastErr = err
+		if !retryable(err) {
+			return err
+		}
+
+		log.Printf("Database not ready (attempt %d): %v; retrying in %s", attempt, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+	return fmt.Errorf("database not ready after %s: %w", timeout, lastErr)
+}
+
+// retryable reports whether err may go away once the server has started:
+// network errors, and PostgreSQL's own "the database system is starting up".
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Name() == "cannot_connect_now"
+	}
+	return true
+}
+
+// connectionString builds a libpq key/value connection string, quoting
+// every value so passwords may contain spaces and quotes.
+func connectionString(cfg config.DatabaseConfig) string {
+	params := map[string]string{
+		"host":        cfg.Host,
+		"port":        fmt.Sprint(cfg.Port),
+		"user":        cfg.User,
+		"password":    cfg.Password,
+		"dbname":      cfg.Name,
+		"sslmode":     cfg.SSLMode,
+		"sslrootcert": cfg.SSLRootCert,
+		"sslcert":     cfg.SSLCert,
+		"sslkey":      cfg.SSLKey,
+	}
+	for key, value := range cfg.Options {
+		if _, ok := params[key]; !ok {
+			params[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "='" + quote.Replace(params[key]) + "'"
+	}
+	return strings.Join(pairs, " ")
 }
\ No newline at end of file
@@ -1,102 +1,101 @@
 /**
  * AuthCallback.tsx - OAuth Callback Handler Component
  * 
- * This component handles the OAuth callback from Google after user authentication.
- * It's the endpoint where the backend redirects users with their JWT token after
- * successful Google OAuth completion.
+ * This component handles the OAuth callback after the user signed in with
+ * a login provider. The backend redirects here with a short-lived,
+ * single-use sign-in code (never the tokens themselves, which would leak
+ * into browser history and Referer headers).
  * 
  * Process Flow:
- * 1. User completes Google OAuth
- * 2. Backend processes OAuth and generates JWT
- * 3. Backend redirects to /auth/callback?token=<jwt>
- * 4. This component extracts the token from URL
- * 5. Decodes JWT to get user information
- * 6. Updates AuthContext with login information
- * 7. Redirects to dashboard
+ * 1. User completes the provider login
+ * 2. Backend processes OAuth and issues a sign-in code
+ * 3. Backend redirects to /auth/callback?code=<code>
+ * 4. This component exchanges the code via POST /auth/exchange
+ * 5. Updates the user store with the returned token and user
+ * 6. Redirects to dashboard
  * 
  * Error Handling:
- * - Missing token: Redirects to login page
- * - Invalid token: Logs error and redirects to login
- * - JWT decode errors: Handles gracefully
+ * - Missing code: Redirects to login page
+ * - Expired or already used code: Logs error and redirects to login
  * 
  * Critical: This component must update the AuthContext properly to ensure
  * authentication state persists across the application.
  */
 
-import { useEffect } from 'react';
+import { useEffect, useRef } from 'react';
 import { useNavigate } from 'react-router-dom';
+import api from '../lib/api';
 import { useUserStore } from '../store/userStore';
 
 /**
  * AuthCallback Component
  * 
  * Processes OAuth callback and establishes user session.
- * This component should only be rendered when users return from Google OAuth.
+ * This component should only be rendered when users return from a provider login.
  */
 const AuthCallback = () => {
   const navigate = useNavigate();
   const { setToken, setUser } = useUserStore();
+  // Codes work once; guards against the effect running twice
+  const exchanged = useRef(false);
 
   /**
    * Effect: Process OAuth callback on component mount
    * 
    * This effect runs once when the component mounts and:
-   * 1. Extracts JWT token from URL parameters
-   * 2. Validates and decodes the token
-   * 3. Creates user object from token claims
-   * 4. Updates AuthContext via login method
+   * 1. Extracts the sign-in code from URL parameters
+   * 2. Exchanges it with the backend for tokens
+   * 3. Creates user object from the response
+   * 4. Updates the user store
    * 5. Redirects to appropriate page
    */
   useEffect(() => {
+    if (exchanged.current) return;
+    exchanged.current = true;
     console.log('AuthCallback: Processing OAuth callback');
     
-    // Extract token from URL query parameters
-    // URL format: /auth/callback?token=<jwt-token>
+    // Extract code from URL query parameters
+    // URL format: /auth/callback?code=<sign-in-code>
     const urlParams = new URLSearchParams(window.location.search);
-    const token = urlParams.get('token');
+    const code = urlParams.get('code');
 
-    if (token) {
-      console.log('AuthCallback: JWT token found in URL');
-      
-      try {
-        // Decode JWT payload to extract user information
-        // JWT structure: header.payload.signature (we need payload)
-        const payload = JSON.parse(atob(token.split('.')[1]));
-        console.log('AuthCallback: Decoded token payload:', {
-          user_id: payload.user_id,
-          username: payload.username,
-          email: payload.email
-        });
-        
+    if (!code) {
+      // No code in URL - OAuth failed or URL was accessed directly
+      console.log('AuthCallback: No sign-in code found, redirecting to login');
+      navigate('/');
+      return;
+    }
+
+    // Remove the code from the address bar and history
+    window.history.replaceState(null, '', window.location.pathname);
+
+    api.post('/auth/exchange', { code })
+      .then(({ data }) => {
         // Create user object matching our User interface
         const user = {
-          user_id: payload.user_id,
-          email: payload.email,
-          username: payload.username,
-          avatar_url: payload.avatar_url,
-          google_id: payload.google_id,
-          provider: payload.provider
+          user_id: data.user.id,
+          email: data.user.email,
+          username: data.user.username,
+          avatar_url: data.user.avatar_url ?? undefined,
+          provider: data.user.provider,
         };
         
         // Update Zustand store with login information
         // This will trigger re-renders across the app and establish session
         console.log('AuthCallback: Updating user store');
-        setToken(token);
+        setToken(data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
         setUser(user);
         
         // Redirect to dashboard (protected route)
         console.log('AuthCallback: Redirecting to dashboard');
         navigate('/dashboard');
-      } catch (error) {
-        // JWT decoding failed - token is malformed
+      })
+      .catch((error) => {
+        // Code expired, was already used or the backend is unreachable
         console.error('AuthCallback: Failed to process authentication:', error);
         navigate('/');
-      }
-    } else {
-      // No token in URL - OAuth failed or URL was accessed directly
-      console.log('AuthCallback: No token found, redirecting to login');
-      navigate('/');
-    }
+      });
   }, [navigate, setToken, setUser]); // Dependencies: navigate and store setters
 
   // Loading UI while processing authentication